@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var secret = []byte("test secret")
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func validClaims(extra jwt.MapClaims) jwt.MapClaims {
+	claims := jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	return claims
+}
+
+// writeJWKS writes the public half of key to a JWKS file under kid.
+func writeJWKS(t *testing.T, kid string, key *rsa.PrivateKey) string {
+	t.Helper()
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"use": "sig",
+		"kid": kid,
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := LoadJWKS(writeJWKS(t, "key-1", rsaKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewVerifier(secret, keys, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := verifier.Verify(sign(t, jwt.SigningMethodHS256, secret, "", validClaims(jwt.MapClaims{
+		"scope":     "accounts:read accounts:write",
+		"tenant_id": "acme",
+		"roles":     []string{RoleAdmin},
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Subject != "alice" || !p.HasScope(ScopeRead) || !p.HasScope(ScopeWrite) || p.HasScope(ScopeDelete) {
+		t.Fatalf("HS256 principal = %+v", p)
+	}
+	if p.TenantID != "acme" || !p.HasRole(RoleAdmin) {
+		t.Fatalf("HS256 principal has tenant %q and roles %v", p.TenantID, p.Roles)
+	}
+
+	p, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, rsaKey, "key-1", validClaims(jwt.MapClaims{"scp": []string{"accounts:delete"}})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.HasScope(ScopeDelete) {
+		t.Fatalf("RS256 principal = %+v", p)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, token := range map[string]string{
+		"wrong secret":  sign(t, jwt.SigningMethodHS256, []byte("other"), "", validClaims(nil)),
+		"expired":       sign(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()}),
+		"no expiry":     sign(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"sub": "alice"}),
+		"unknown kid":   sign(t, jwt.SigningMethodRS256, rsaKey, "key-2", validClaims(nil)),
+		"wrong rsa key": sign(t, jwt.SigningMethodRS256, otherKey, "key-1", validClaims(nil)),
+		"HS384":         sign(t, jwt.SigningMethodHS384, secret, "", validClaims(nil)),
+		"garbage":       "not.a.token",
+	} {
+		if _, err := verifier.Verify(token); err == nil {
+			t.Errorf("%s: token was accepted", name)
+		}
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	verifier, err := NewVerifier(secret, nil, "issuer", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	interceptor := NewInterceptor(verifier, Policy{
+		Scopes: map[string]string{"/svc/Delete": ScopeDelete},
+		Public: map[string]bool{"/svc/Health": true},
+	})
+	unary := interceptor.Unary()
+	call := func(method, authorization string) (*Principal, error) {
+		ctx := context.Background()
+		if authorization != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", authorization))
+		}
+		var principal *Principal
+		_, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+			principal, _ = FromContext(ctx)
+			return nil, nil
+		})
+		return principal, err
+	}
+	bearer := func(claims jwt.MapClaims) string {
+		return "Bearer " + sign(t, jwt.SigningMethodHS256, secret, "", validClaims(claims))
+	}
+
+	for _, tc := range []struct {
+		name, method, authorization string
+		want                        codes.Code
+	}{
+		{"public", "/svc/Health", "", codes.OK},
+		{"granted", "/svc/Delete", bearer(jwt.MapClaims{"iss": "issuer", "scope": ScopeDelete}), codes.OK},
+		{"missing token", "/svc/Delete", "", codes.Unauthenticated},
+		{"not bearer", "/svc/Delete", "Basic dXNlcjpwYXNz", codes.Unauthenticated},
+		{"wrong issuer", "/svc/Delete", bearer(jwt.MapClaims{"iss": "other", "scope": ScopeDelete}), codes.Unauthenticated},
+		{"missing scope", "/svc/Delete", bearer(jwt.MapClaims{"iss": "issuer", "scope": ScopeWrite}), codes.PermissionDenied},
+		{"unlisted method", "/svc/New", bearer(jwt.MapClaims{"iss": "issuer", "scope": ScopeDelete}), codes.PermissionDenied},
+	} {
+		principal, err := call(tc.method, tc.authorization)
+		if got := status.Code(err); got != tc.want {
+			t.Errorf("%s: got %v, want %v (%v)", tc.name, got, tc.want, err)
+			continue
+		}
+		if tc.name == "granted" && (principal == nil || principal.Subject != "alice") {
+			t.Errorf("granted: handler saw principal %+v", principal)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Policy maps full gRPC method names to the scope they require. Methods
+// listed in Public need no token; any other method that has no scope is
+// denied, so a new RPC is unreachable until it is given one.
+type Policy struct {
+	Scopes map[string]string
+	Public map[string]bool
+}
+
+// Interceptor authenticates RPCs against a Policy and stores the
+// principal in the handler's context.
+type Interceptor struct {
+	verifier *Verifier
+	policy   Policy
+
+	// OnAuthenticated, if set, is called with each authorized principal
+	// and returns the context for the handler. An error rejects the RPC.
+	OnAuthenticated func(ctx context.Context, p *Principal) (context.Context, error)
+}
+
+func NewInterceptor(verifier *Verifier, policy Policy) *Interceptor {
+	return &Interceptor{verifier: verifier, policy: policy}
+}
+
+func (i *Interceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := i.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (i *Interceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := i.authorize(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+func (i *Interceptor) authorize(ctx context.Context, method string) (context.Context, error) {
+	if i.policy.Public[method] {
+		return ctx, nil
+	}
+	scope, ok := i.policy.Scopes[method]
+	if !ok {
+		return nil, status.Errorf(codes.PermissionDenied, "%s is not allowed", method)
+	}
+
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	principal, err := i.verifier.Verify(token)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+	}
+	if !principal.HasScope(scope) {
+		return nil, status.Errorf(codes.PermissionDenied, "token lacks the %s scope", scope)
+	}
+	ctx = NewContext(ctx, principal)
+	if i.OnAuthenticated != nil {
+		return i.OnAuthenticated(ctx, principal)
+	}
+	return ctx, nil
+}
+
+func bearerToken(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", status.Errorf(codes.Unauthenticated, "missing authorization metadata")
+	}
+	scheme, token, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", status.Errorf(codes.Unauthenticated, "authorization metadata must be a bearer token")
+	}
+	return token, nil
+}
+
+// contextStream overrides the context of a server stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
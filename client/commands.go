@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/money"
+	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newFlagSet returns the flag set of a command; arguments describes its
+// positional arguments in the usage message.
+func newFlagSet(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: client [flags] %s [flags] %s\n", name, arguments)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses args into fs and checks that exactly n positional
+// arguments remain.
+func parseArgs(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		// The flag package has already reported the error.
+		return nil, usageError{}
+	}
+	if fs.NArg() != n {
+		fs.Usage()
+		return nil, usagef("%s takes %d argument(s), got %d", fs.Name(), n, fs.NArg())
+	}
+	return fs.Args(), nil
+}
+
+// moneyFlags registers -amount and -currency flags, prefixed with prefix.
+func moneyFlags(fs *flag.FlagSet, prefix, description string) func() (*pb.Money, error) {
+	amount := fs.String(prefix+"amount", "", description+" as a decimal in the major unit of the currency, e.g. 12.34")
+	currency := fs.String(prefix+"currency", "USD", "ISO 4217 currency of -"+prefix+"amount")
+	return func() (*pb.Money, error) {
+		if *amount == "" {
+			return nil, usagef("-%samount is required", prefix)
+		}
+		m, err := money.Parse(*currency, *amount)
+		if err != nil {
+			return nil, usageError{err}
+		}
+		return &pb.Money{Currency: m.Currency, Amount: m.Amount}, nil
+	}
+}
+
+// rpcContext bounds a single RPC by the -timeout flag.
+func rpcContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, *timeout)
+}
+
+func runCreate(ctx context.Context, client pb.CommerceTransactionsClient, args []string) error {
+	fs := newFlagSet("create", "")
+	balance := moneyFlags(fs, "", "Opening balance")
+	idempotencyKey := fs.String("idempotency-key", "", "Key that makes retrying this command safe.")
+	pending := fs.Bool("pending", false, "Open the transaction pending; it refuses changes until made active with the status command.")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	req := &pb.CreateTransactionRequest{IdempotencyKey: *idempotencyKey, Pending: *pending}
+	var err error
+	if req.Balance, err = balance(); err != nil {
+		return err
+	}
+
+	ctx, cancel := rpcContext(ctx)
+	defer cancel()
+	res, err := client.CreateTransaction(ctx, req)
+	if err != nil {
+		return err
+	}
+	return render(res, func(w *tabwriter.Writer) {
+		transactionTable(w, res.TransactionId, res.Balance, res.Version, res.Status)
+	})
+}
+
+func runGet(ctx context.Context, client pb.CommerceTransactionsClient, args []string) error {
+	fs := newFlagSet("get", "TRANSACTION_ID")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := rpcContext(ctx)
+	defer cancel()
+	res, err := client.GetTransaction(ctx, &pb.GetTransactionRequest{TransactionId: positional[0]})
+	if err != nil {
+		return err
+	}
+	return render(res, func(w *tabwriter.Writer) {
+		transactionTable(w, res.TransactionId, res.Balance, res.Version, res.Status)
+		if res.StatusReason != "" {
+			fmt.Fprintf(w, "\nStatus reason: %s\n", res.StatusReason)
+		}
+	})
+}
+
+func runUpdate(ctx context.Context, client pb.CommerceTransactionsClient, args []string) error {
+	fs := newFlagSet("update", "TRANSACTION_ID")
+	balance := moneyFlags(fs, "", "New balance")
+	expectedVersion := fs.Int64("expected-version", 0, "Only update if the transaction is still at this version, from a previous get.")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	req := &pb.UpdateTransactionRequest{TransactionId: positional[0]}
+	if req.Balance, err = balance(); err != nil {
+		return err
+	}
+	if isSet(fs, "expected-version") {
+		req.ExpectedVersion = expectedVersion
+	}
+
+	ctx, cancel := rpcContext(ctx)
+	defer cancel()
+	res, err := client.UpdateTransaction(ctx, req)
+	if err != nil {
+		return err
+	}
+	return render(res, func(w *tabwriter.Writer) {
+		transactionTable(w, res.TransactionId, res.Balance, res.Version, res.Status)
+	})
+}
+
+func runDelete(ctx context.Context, client pb.CommerceTransactionsClient, args []string) error {
+	fs := newFlagSet("delete", "TRANSACTION_ID")
+	force := fs.Bool("force", false, "Delete the transaction even if it has a balance, returning it to the external account.")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := rpcContext(ctx)
+	defer cancel()
+	res, err := client.DeleteTransaction(ctx, &pb.DeleteTransactionRequest{TransactionId: positional[0], Force: *force})
+	if err != nil {
+		return err
+	}
+	return render(res, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, res.Message)
+	})
+}
+
+func runRestore(ctx context.Context, client pb.CommerceTransactionsClient, args []string) error {
+	fs := newFlagSet("restore", "TRANSACTION_ID")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := rpcContext(ctx)
+	defer cancel()
+	res, err := client.RestoreTransaction(ctx, &pb.RestoreTransactionRequest{TransactionId: positional[0]})
+	if err != nil {
+		return err
+	}
+	return render(res, func(w *tabwriter.Writer) {
+		transactionTable(w, res.TransactionId, res.Balance, res.Version, res.Status)
+	})
+}
+
+func runStatus(ctx context.Context, client pb.CommerceTransactionsClient, args []string) error {
+	fs := newFlagSet("status", "TRANSACTION_ID pending|active|frozen|closed")
+	reason := fs.String("reason", "", "Why the status is changing; required.")
+	positional, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	transactionStatus, ok := parseStatus(positional[1])
+	if !ok {
+		return usagef("unknown status %q", positional[1])
+	}
+	if *reason == "" {
+		return usagef("-reason is required")
+	}
+
+	ctx, cancel := rpcContext(ctx)
+	defer cancel()
+	res, err := client.SetTransactionStatus(ctx, &pb.SetTransactionStatusRequest{
+		TransactionId: positional[0],
+		Status:        transactionStatus,
+		Reason:        *reason,
+	})
+	if err != nil {
+		return err
+	}
+	return render(res, func(w *tabwriter.Writer) {
+		transactionTable(w, res.TransactionId, res.Balance, res.Version, res.Status)
+	})
+}
+
+func runList(ctx context.Context, client pb.CommerceTransactionsClient, args []string) error {
+	fs := newFlagSet("list", "")
+	pageSize := fs.Int("page-size", 0, "Maximum number of transactions per page; the server default if zero.")
+	pageToken := fs.String("page-token", "", "Continue from a previous list.")
+	all := fs.Bool("all", false, "Follow page tokens until every matching transaction is listed.")
+	descending := fs.Bool("desc", false, "List the newest transactions first.")
+	currency := fs.String("currency", "", "Only list transactions in this currency.")
+	minBalance := fs.String("min-balance", "", "Only list transactions with at least this balance; requires -currency.")
+	maxBalance := fs.String("max-balance", "", "Only list transactions with at most this balance; requires -currency.")
+	createdAfter := fs.String("created-after", "", "Only list transactions created at or after this RFC 3339 time.")
+	createdBefore := fs.String("created-before", "", "Only list transactions created before this RFC 3339 time.")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	req := &pb.ListTransactionsRequest{
+		PageSize:  int32(*pageSize),
+		PageToken: *pageToken,
+		Currency:  *currency,
+	}
+	if *descending {
+		req.Order = pb.SortOrder_SORT_ORDER_CREATED_DESC
+	}
+	for _, bound := range []struct {
+		name, value string
+		dst         **int64
+	}{
+		{"min-balance", *minBalance, &req.MinBalance},
+		{"max-balance", *maxBalance, &req.MaxBalance},
+	} {
+		if bound.value == "" {
+			continue
+		}
+		// Balances are compared in minor units, so the currency decides
+		// how the decimal is read.
+		if *currency == "" {
+			return usagef("-%s requires -currency", bound.name)
+		}
+		m, err := money.Parse(*currency, bound.value)
+		if err != nil {
+			return usageError{err}
+		}
+		*bound.dst = &m.Amount
+	}
+	for _, bound := range []struct {
+		name, value string
+		dst         **timestamppb.Timestamp
+	}{
+		{"created-after", *createdAfter, &req.CreatedAfter},
+		{"created-before", *createdBefore, &req.CreatedBefore},
+	} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			return usagef("invalid -%s: %v", bound.name, err)
+		}
+		*bound.dst = timestamppb.New(t)
+	}
+
+	page := &pb.ListTransactionsResponse{}
+	for {
+		rpcCtx, cancel := rpcContext(ctx)
+		res, err := client.ListTransactions(rpcCtx, req)
+		cancel()
+		if err != nil {
+			return err
+		}
+		page.Transactions = append(page.Transactions, res.Transactions...)
+		page.NextPageToken = res.NextPageToken
+		if !*all || res.NextPageToken == "" {
+			break
+		}
+		req.PageToken = res.NextPageToken
+	}
+
+	return render(page, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tBALANCE\tCURRENCY\tVERSION\tSTATUS\tCREATED")
+		for _, transaction := range page.Transactions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n", transaction.TransactionId,
+				formatMoney(transaction.Balance), transaction.Balance.GetCurrency(), transaction.Version,
+				formatStatus(transaction.Status), transaction.CreatedAt.AsTime().Format(time.RFC3339))
+		}
+		if page.NextPageToken != "" {
+			fmt.Fprintf(w, "\nNext page: -page-token %s\n", strconv.Quote(page.NextPageToken))
+		}
+	})
+}
+
+func runTransfer(ctx context.Context, client pb.CommerceTransactionsClient, args []string) error {
+	fs := newFlagSet("transfer", "FROM_ID TO_ID")
+	amount := moneyFlags(fs, "", "Amount debited from FROM_ID")
+	converted := moneyFlags(fs, "converted-", "For transfers between currencies, the amount credited to TO_ID")
+	idempotencyKey := fs.String("idempotency-key", "", "Key that makes retrying this command safe.")
+	positional, err := parseArgs(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	req := &pb.TransferFundsRequest{
+		FromId:         positional[0],
+		ToId:           positional[1],
+		IdempotencyKey: *idempotencyKey,
+	}
+	if req.Amount, err = amount(); err != nil {
+		return err
+	}
+	if isSet(fs, "converted-amount") {
+		if req.ConvertedAmount, err = converted(); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := rpcContext(ctx)
+	defer cancel()
+	res, err := client.TransferFunds(ctx, req)
+	if err != nil {
+		return err
+	}
+	return render(res, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tBALANCE\tCURRENCY")
+		fmt.Fprintf(w, "%s\t%s\t%s\n", req.FromId, formatMoney(res.FromBalance), res.FromBalance.GetCurrency())
+		fmt.Fprintf(w, "%s\t%s\t%s\n", req.ToId, formatMoney(res.ToBalance), res.ToBalance.GetCurrency())
+	})
+}
+
+// isSet reports whether the flag name was given on the command line.
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -1,41 +1,231 @@
+// Command client is a command-line client for the CommerceTransactions
+// service, meant for manual inspection and intervention.
+//
+// Usage:
+//
+//	client [flags] <command> [command flags] [arguments]
+//
+// Run "client -h" for the global flags and "client <command> -h" for the
+// flags of a command. The exit status is 0 on success, 1 for failures
+// outside an RPC such as unreadable certificates, 2 for invalid command
+// lines, and 10 plus the gRPC status code for failed RPCs, e.g. 15 for
+// NOT_FOUND.
 package main
 
 import (
 	"context"
+	"errors"
 	"flag"
-	"log/slog"
+	"fmt"
+	"os"
+	"sort"
 	"time"
 
 	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+	"github.com/yaninyzwitty/golang-proj-with-db/tlsconfig"
+	"github.com/yaninyzwitty/golang-proj-with-db/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 )
 
 var (
-	addr = flag.String("addr", ":50051", "The address to listen on for GRPC requests.")
+	addr       = flag.String("addr", "localhost:50051", "The address of the GRPC server.")
+	timeout    = flag.Duration("timeout", 10*time.Second, "Deadline for each RPC.")
+	useTLS     = flag.Bool("tls", false, "Connect over TLS.")
+	caFile     = flag.String("ca-file", "", "PEM file with the CA certificates to trust, instead of the system pool. Implies -tls.")
+	certFile   = flag.String("cert-file", "", "PEM client certificate, for servers that require one. Implies -tls.")
+	keyFile    = flag.String("key-file", "", "PEM private key of -cert-file.")
+	serverName = flag.String("server-name", "", "Override the server name used to verify the server certificate.")
+	output     = flag.String("output", "table", `Output format, "table" or "json".`)
+	token      = flag.String("token", os.Getenv("AUTH_TOKEN"), "JWT bearer token sent with every RPC. Defaults to $AUTH_TOKEN.")
+	traceRPCs  = flag.Bool("trace", false, "Trace RPCs, propagating the trace context to the server, and print the spans to stderr.")
 )
 
+const (
+	// exitFailure is the exit status for errors that did not come from the
+	// server.
+	exitFailure = 1
+	// exitUsage is the exit status for invalid command lines, as used by
+	// the flag package.
+	exitUsage = 2
+	// exitRPC is added to the gRPC status code of failed RPCs, so they
+	// never collide with the statuses above.
+	exitRPC = 10
+)
+
+// command is a subcommand of the CLI.
+type command struct {
+	summary string
+	run     func(ctx context.Context, client pb.CommerceTransactionsClient, args []string) error
+}
+
+var commands = map[string]command{
+	"create":   {"Create a transaction with an opening balance", runCreate},
+	"get":      {"Show a transaction", runGet},
+	"update":   {"Set the balance of a transaction", runUpdate},
+	"delete":   {"Delete a transaction", runDelete},
+	"restore":  {"Restore a deleted transaction", runRestore},
+	"status":   {"Activate, freeze, unfreeze or close a transaction", runStatus},
+	"list":     {"List transactions", runList},
+	"transfer": {"Move funds between two transactions", runTransfer},
+}
+
+// usageError is returned by commands whose arguments are invalid.
+// A nil err means the problem has already been reported.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string {
+	if e.err == nil {
+		return "invalid usage"
+	}
+	return e.err.Error()
+}
+
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
+
 func main() {
+	flag.Usage = usage
 	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(exitUsage)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(exitUsage)
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", *output)
+		os.Exit(exitUsage)
+	}
+
+	shutdownTracing := func(context.Context) error { return nil }
+	if *traceRPCs {
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stderr), stdouttrace.WithPrettyPrint())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitFailure)
+		}
+		shutdownTracing = tracing.Setup(exporter, "client")
+	}
+
 	// Set up a connection to the server.
-	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	creds, err := transportCredentials()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitFailure)
+	}
+	options := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	}
+	if *token != "" {
+		options = append(options, grpc.WithPerRPCCredentials(bearerToken{
+			token:      *token,
+			requireTLS: creds.Info().SecurityProtocol == "tls",
+		}))
+	}
+	conn, err := grpc.NewClient(*addr, options...)
 	if err != nil {
-		slog.Error("failed to connrc: %v", err)
+		fmt.Fprintf(os.Stderr, "failed to connect: %v\n", err)
+		os.Exit(exitFailure)
 	}
 
-	defer conn.Close()
-	client := pb.NewCommerceTransactionsClient(conn)
+	err = cmd.run(context.Background(), pb.NewCommerceTransactionsClient(conn), flag.Args()[1:])
+	conn.Close()
+	shutdownTracing(context.Background())
+	os.Exit(exitCode(err))
+}
 
-	// Contact the server and print out its response.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// exitCode prints err and returns the process exit status for it: exitRPC
+// plus the gRPC status code for failed RPCs, exitUsage for invalid
+// arguments and exitFailure for anything else.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	var usageErr usageError
+	if errors.As(err, &usageErr) {
+		if usageErr.err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		}
+		return exitUsage
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintf(os.Stderr, "error: %s: %s\n", st.Code(), st.Message())
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			fmt.Fprintf(os.Stderr, "  reason: %s", detail.Reason)
+			for key, value := range detail.Metadata {
+				fmt.Fprintf(os.Stderr, " %s=%s", key, value)
+			}
+			fmt.Fprintln(os.Stderr)
+		case *errdetails.BadRequest:
+			for _, violation := range detail.FieldViolations {
+				fmt.Fprintf(os.Stderr, "  %s: %s\n", violation.Field, violation.Description)
+			}
+		}
+	}
+	return exitRPC + int(st.Code())
+}
 
-	res, err := client.CreateTransaction(ctx, &pb.CreateTransactionRequest{
-		Balance: 500,
-	})
+func transportCredentials() (credentials.TransportCredentials, error) {
+	if !*useTLS && *caFile == "" && *certFile == "" {
+		return insecure.NewCredentials(), nil
+	}
+	config, err := tlsconfig.ClientConfig(*certFile, *keyFile, *caFile, *serverName)
 	if err != nil {
-		slog.Error("failed to create a transaction: %v", err)
+		return nil, err
 	}
-	slog.Info("Transaction", "your transaction id: ", res.TransactionId)
+	return credentials.NewTLS(config), nil
+}
+
+// bearerToken sends a JWT in the authorization metadata of every RPC.
+type bearerToken struct {
+	token string
+	// requireTLS stops the token from being sent in plaintext once TLS
+	// has been asked for.
+	requireTLS bool
+}
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool {
+	return t.requireTLS
+}
 
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] <command> [command flags] [arguments]\n\nCommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/money"
+	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// render writes res to stdout: as protobuf JSON with -output json,
+// otherwise as the table that table writes.
+func render(res proto.Message, table func(w *tabwriter.Writer)) error {
+	if *output == "json" {
+		out, err := protojson.MarshalOptions{Multiline: true, EmitUnpopulated: true}.Marshal(res)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(os.Stdout, string(out))
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+func transactionTable(w *tabwriter.Writer, id string, balance *pb.Money, version int64, status pb.TransactionStatus) {
+	fmt.Fprintln(w, "ID\tBALANCE\tCURRENCY\tVERSION\tSTATUS")
+	fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", id, formatMoney(balance), balance.GetCurrency(), version, formatStatus(status))
+}
+
+// formatStatus returns the lower-case name of s, as the status command
+// takes it, e.g. "frozen".
+func formatStatus(s pb.TransactionStatus) string {
+	return strings.ToLower(strings.TrimPrefix(s.String(), "TRANSACTION_STATUS_"))
+}
+
+// parseStatus is the inverse of formatStatus.
+func parseStatus(s string) (pb.TransactionStatus, bool) {
+	value, ok := pb.TransactionStatus_value["TRANSACTION_STATUS_"+strings.ToUpper(s)]
+	if !ok || value == int32(pb.TransactionStatus_TRANSACTION_STATUS_UNSPECIFIED) {
+		return pb.TransactionStatus_TRANSACTION_STATUS_UNSPECIFIED, false
+	}
+	return pb.TransactionStatus(value), true
+}
+
+// formatMoney formats m as a decimal in its currency's major unit.
+func formatMoney(m *pb.Money) string {
+	return money.New(m.GetCurrency(), m.GetAmount()).Decimal()
+}
@@ -3,26 +3,165 @@ package config
 import (
 	"log/slog"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	PORT         string
-	DATABASE_URL string
+	PORT             string
+	DATABASE_URL     string
+	MIGRATE_ON_START bool
+	// How long in-flight RPCs may take to finish on shutdown before their
+	// connections are closed.
+	SHUTDOWN_TIMEOUT time.Duration
+	// Serve gRPC reflection, for tools like grpcurl.
+	ENABLE_REFLECTION bool
+	// LOG_LEVEL is one of debug, info, warn or error, and LOG_FORMAT is
+	// text or json.
+	LOG_LEVEL  string
+	LOG_FORMAT string
+
+	// TLS is enabled when TLS_CERT_FILE is set. TLS_CA_FILE verifies
+	// client certificates, which are required with
+	// TLS_REQUIRE_CLIENT_CERT. The files are checked for changes every
+	// TLS_RELOAD_INTERVAL.
+	TLS_CERT_FILE           string
+	TLS_KEY_FILE            string
+	TLS_CA_FILE             string
+	TLS_REQUIRE_CLIENT_CERT bool
+	TLS_RELOAD_INTERVAL     time.Duration
+
+	// Bearer tokens are verified with an HS256 shared secret, or a JWKS
+	// file of RS256 public keys, or both. The issuer and audience are
+	// checked when set. The server refuses to start without a key source
+	// unless AUTH_DISABLED is set, which serves every tenant to anyone.
+	AUTH_DISABLED     bool
+	AUTH_HS256_SECRET string
+	AUTH_JWKS_FILE    string
+	AUTH_ISSUER       string
+	AUTH_AUDIENCE     string
+
+	// Connection pool settings, see database.NewPool.
+	DB_MAX_CONNS           int32
+	DB_MIN_CONNS           int32
+	DB_MAX_CONN_IDLE_TIME  time.Duration
+	DB_MAX_CONN_LIFETIME   time.Duration
+	DB_HEALTH_CHECK_PERIOD time.Duration
+	DB_CONNECT_TIMEOUT     time.Duration
+
+	// How often the health service pings the database, and how long a
+	// ping may take before the server reports NOT_SERVING.
+	HEALTH_CHECK_INTERVAL time.Duration
+	HEALTH_CHECK_TIMEOUT  time.Duration
+
+	// Address of the HTTP listener serving Prometheus metrics on /metrics,
+	// e.g. ":9090". Metrics are not served if it is empty.
+	METRICS_ADDR string
+
+	// Where traces are exported: "none", "stdout" to print spans, or
+	// "otlp" to send them over gRPC to TRACING_OTLP_ENDPOINT, in plaintext
+	// with TRACING_OTLP_INSECURE.
+	TRACING_EXPORTER      string
+	TRACING_OTLP_ENDPOINT string
+	TRACING_OTLP_INSECURE bool
+
+	// How long idempotency keys are remembered, and how often expired
+	// keys are deleted. IDEMPOTENCY_LEASE is how long a key stays reserved
+	// while its request runs; a retry after a crash in that time is
+	// refused until the lease expires, and then applied again.
+	IDEMPOTENCY_TTL            time.Duration
+	IDEMPOTENCY_LEASE          time.Duration
+	IDEMPOTENCY_PURGE_INTERVAL time.Duration
+
+	// How long deleted accounts can be restored before they are purged,
+	// and how often expired deletions are purged.
+	DELETED_RETENTION      time.Duration
+	DELETED_PURGE_INTERVAL time.Duration
+
+	// Where WatchTransactions gets changes from: "changefeed", "poll", or
+	// "auto" to use a changefeed and fall back to polling when it is
+	// unavailable.
+	WATCH_SOURCE            string
+	WATCH_POLL_INTERVAL     time.Duration
+	WATCH_RESOLVED_INTERVAL time.Duration
+
+	// Where the outbox relay delivers account events: "file" appends JSON
+	// lines to OUTBOX_FILE, "webhook" POSTs them to OUTBOX_WEBHOOK_URL and
+	// "channel" hands them to a consumer inside the server, which logs them.
+	OUTBOX_SINK          string
+	OUTBOX_FILE          string
+	OUTBOX_WEBHOOK_URL   string
+	OUTBOX_POLL_INTERVAL time.Duration
+	OUTBOX_MAX_BACKOFF   time.Duration
+	OUTBOX_BATCH_SIZE    int
 }
 
 func NewConfig() *Config {
 	err := godotenv.Load()
 
 	if err != nil {
-		slog.Error("Error loading .env file", err)
+		slog.Error("Error loading .env file", "error", err)
 	}
 	// Return the Config struct with values from environment variables or defaults.
 
 	return &Config{
-		PORT:         getEnv("PORT", "50051"),
-		DATABASE_URL: getEnv("DATABASE_URL", "localhost:5432"),
+		PORT:             getEnv("PORT", "50051"),
+		DATABASE_URL:     getEnv("DATABASE_URL", "localhost:5432"),
+		MIGRATE_ON_START: getEnvBool("MIGRATE_ON_START", true),
+		SHUTDOWN_TIMEOUT: getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+
+		ENABLE_REFLECTION: getEnvBool("ENABLE_REFLECTION", false),
+
+		LOG_LEVEL:  getEnv("LOG_LEVEL", "info"),
+		LOG_FORMAT: getEnv("LOG_FORMAT", "text"),
+
+		TLS_CERT_FILE:           getEnv("TLS_CERT_FILE", ""),
+		TLS_KEY_FILE:            getEnv("TLS_KEY_FILE", ""),
+		TLS_CA_FILE:             getEnv("TLS_CA_FILE", ""),
+		TLS_REQUIRE_CLIENT_CERT: getEnvBool("TLS_REQUIRE_CLIENT_CERT", false),
+		TLS_RELOAD_INTERVAL:     getEnvDuration("TLS_RELOAD_INTERVAL", 30*time.Second),
+
+		AUTH_DISABLED:     getEnvBool("AUTH_DISABLED", false),
+		AUTH_HS256_SECRET: getEnv("AUTH_HS256_SECRET", ""),
+		AUTH_JWKS_FILE:    getEnv("AUTH_JWKS_FILE", ""),
+		AUTH_ISSUER:       getEnv("AUTH_ISSUER", ""),
+		AUTH_AUDIENCE:     getEnv("AUTH_AUDIENCE", ""),
+
+		DB_MAX_CONNS:           int32(getEnvInt("DB_MAX_CONNS", 10)),
+		DB_MIN_CONNS:           int32(getEnvInt("DB_MIN_CONNS", 2)),
+		DB_MAX_CONN_IDLE_TIME:  getEnvDuration("DB_MAX_CONN_IDLE_TIME", 5*time.Minute),
+		DB_MAX_CONN_LIFETIME:   getEnvDuration("DB_MAX_CONN_LIFETIME", time.Hour),
+		DB_HEALTH_CHECK_PERIOD: getEnvDuration("DB_HEALTH_CHECK_PERIOD", 30*time.Second),
+		DB_CONNECT_TIMEOUT:     getEnvDuration("DB_CONNECT_TIMEOUT", 10*time.Second),
+
+		HEALTH_CHECK_INTERVAL: getEnvDuration("HEALTH_CHECK_INTERVAL", 5*time.Second),
+		HEALTH_CHECK_TIMEOUT:  getEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+
+		METRICS_ADDR: getEnv("METRICS_ADDR", ""),
+
+		TRACING_EXPORTER:      getEnv("TRACING_EXPORTER", "none"),
+		TRACING_OTLP_ENDPOINT: getEnv("TRACING_OTLP_ENDPOINT", "localhost:4317"),
+		TRACING_OTLP_INSECURE: getEnvBool("TRACING_OTLP_INSECURE", false),
+
+		IDEMPOTENCY_TTL:            getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		IDEMPOTENCY_LEASE:          getEnvDuration("IDEMPOTENCY_LEASE", time.Minute),
+		IDEMPOTENCY_PURGE_INTERVAL: getEnvDuration("IDEMPOTENCY_PURGE_INTERVAL", 10*time.Minute),
+
+		DELETED_RETENTION:      getEnvDuration("DELETED_RETENTION", 30*24*time.Hour),
+		DELETED_PURGE_INTERVAL: getEnvDuration("DELETED_PURGE_INTERVAL", time.Hour),
+
+		WATCH_SOURCE:            getEnv("WATCH_SOURCE", "auto"),
+		WATCH_POLL_INTERVAL:     getEnvDuration("WATCH_POLL_INTERVAL", time.Second),
+		WATCH_RESOLVED_INTERVAL: getEnvDuration("WATCH_RESOLVED_INTERVAL", 10*time.Second),
+
+		OUTBOX_SINK:          getEnv("OUTBOX_SINK", "file"),
+		OUTBOX_FILE:          getEnv("OUTBOX_FILE", "outbox.jsonl"),
+		OUTBOX_WEBHOOK_URL:   getEnv("OUTBOX_WEBHOOK_URL", ""),
+		OUTBOX_POLL_INTERVAL: getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+		OUTBOX_MAX_BACKOFF:   getEnvDuration("OUTBOX_MAX_BACKOFF", time.Minute),
+		OUTBOX_BATCH_SIZE:    getEnvInt("OUTBOX_BATCH_SIZE", 100),
 	}
 }
 func getEnv(key, defaultValue string) string {
@@ -32,3 +171,42 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Error("invalid boolean in environment, using default", "key", key, "value", value)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Error("invalid integer in environment, using default", "key", key, "value", value)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Error("invalid duration in environment, using default", "key", key, "value", value)
+		return defaultValue
+	}
+	return parsed
+}
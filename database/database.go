@@ -0,0 +1,55 @@
+// Package database builds the shared pgx connection pool used by every
+// handler. A pool, unlike a single *pgx.Conn, is safe for concurrent use by
+// the goroutines grpc-go serves each RPC on.
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yaninyzwitty/golang-proj-with-db/config"
+	"github.com/yaninyzwitty/golang-proj-with-db/tracing"
+)
+
+// NewPool parses cfg.DATABASE_URL, applies the pool limits from cfg and
+// verifies that a connection can be established.
+func NewPool(ctx context.Context, cfg *config.Config) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.DATABASE_URL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing connection configuration: %w", err)
+	}
+
+	poolConfig.ConnConfig.RuntimeParams["application_name"] = "docs_simplecrud_gopgx" //for debugging, not really necessary
+	// Trace queries and pool waits through the global tracer provider.
+	poolConfig.ConnConfig.Tracer = tracing.NewQueryTracer()
+	poolConfig.MaxConns = cfg.DB_MAX_CONNS
+	poolConfig.MinConns = cfg.DB_MIN_CONNS
+	poolConfig.MaxConnIdleTime = cfg.DB_MAX_CONN_IDLE_TIME
+	poolConfig.MaxConnLifetime = cfg.DB_MAX_CONN_LIFETIME
+	poolConfig.HealthCheckPeriod = cfg.DB_HEALTH_CHECK_PERIOD
+	if poolConfig.MinConns > poolConfig.MaxConns {
+		return nil, fmt.Errorf("DB_MIN_CONNS (%d) exceeds DB_MAX_CONNS (%d)", poolConfig.MinConns, poolConfig.MaxConns)
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("creating connection pool: %w", err)
+	}
+
+	// NewWithConfig connects lazily; ping so a bad URL fails at startup
+	// rather than on the first RPC.
+	if err := Ping(ctx, pool, cfg.DB_CONNECT_TIMEOUT); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	return pool, nil
+}
+
+// Ping checks that the database answers within timeout.
+func Ping(ctx context.Context, pool *pgxpool.Pool, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return pool.Ping(ctx)
+}
@@ -0,0 +1,122 @@
+// Package domain defines the errors clients can act on, each with a gRPC
+// code and a stable reason sent as google.rpc.ErrorInfo. Their reasons
+// are part of the API: clients branch on them, so they never change.
+package domain
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain is the ErrorInfo domain of every Error.
+const ErrorDomain = "commerce_transactions"
+
+// Error is a failure that is safe to report to clients.
+type Error struct {
+	// Reason identifies the error, e.g. "ACCOUNT_NOT_FOUND".
+	Reason string
+	Code   codes.Code
+	// Message describes the error to people, and is sent to the client.
+	Message string
+	// Metadata is sent as ErrorInfo metadata, e.g. the ID of the account
+	// concerned.
+	Metadata map[string]string
+}
+
+var (
+	ErrAccountNotFound     = newError("ACCOUNT_NOT_FOUND", codes.NotFound, "account not found")
+	ErrInsufficientFunds   = newError("INSUFFICIENT_FUNDS", codes.FailedPrecondition, "insufficient funds")
+	ErrAccountFrozen       = newError("ACCOUNT_FROZEN", codes.FailedPrecondition, "account is frozen")
+	ErrAccountClosed       = newError("ACCOUNT_CLOSED", codes.FailedPrecondition, "account is closed")
+	ErrAccountPending      = newError("ACCOUNT_PENDING", codes.FailedPrecondition, "account is not active yet")
+	ErrStatusTransition    = newError("INVALID_STATUS_TRANSITION", codes.FailedPrecondition, "account cannot change to the requested status")
+	ErrVersionConflict     = newError("VERSION_CONFLICT", codes.Aborted, "account was modified concurrently")
+	ErrInvalidAmount       = newError("INVALID_AMOUNT", codes.InvalidArgument, "amount must be positive")
+	ErrSameAccount         = newError("SAME_ACCOUNT", codes.InvalidArgument, "source and destination accounts must differ")
+	ErrCurrencyMismatch    = newError("CURRENCY_MISMATCH", codes.FailedPrecondition, "currency mismatch")
+	ErrConversionRequired  = newError("CONVERSION_REQUIRED", codes.FailedPrecondition, "cross-currency transfer requires a conversion")
+	ErrBalanceNotZero      = newError("BALANCE_NOT_ZERO", codes.FailedPrecondition, "account has a non-zero balance; force the deletion to close it")
+	ErrAccountNotDeleted   = newError("ACCOUNT_NOT_DELETED", codes.FailedPrecondition, "account is not deleted")
+	ErrIdempotencyKeyReuse = newError("IDEMPOTENCY_KEY_REUSED", codes.AlreadyExists, "idempotency key was already used for a different request")
+	ErrIdempotencyPending  = newError("IDEMPOTENCY_KEY_IN_PROGRESS", codes.Aborted, "a request with this idempotency key is still in progress")
+
+	// The request errors below carry the offending request field as
+	// "field" metadata where there is one.
+	ErrInvalidArgument  = newError("INVALID_ARGUMENT", codes.InvalidArgument, "invalid argument")
+	ErrMissingField     = newError("MISSING_FIELD", codes.InvalidArgument, "required field is missing")
+	ErrInvalidID        = newError("INVALID_ID", codes.InvalidArgument, "invalid ID")
+	ErrInvalidCurrency  = newError("INVALID_CURRENCY", codes.InvalidArgument, "unknown currency")
+	ErrInvalidStatus    = newError("INVALID_STATUS", codes.InvalidArgument, "invalid status")
+	ErrInvalidPageToken = newError("INVALID_PAGE_TOKEN", codes.InvalidArgument, "invalid page token")
+	ErrInvalidPageSize  = newError("INVALID_PAGE_SIZE", codes.InvalidArgument, "page size must not be negative")
+	ErrInvalidCursor    = newError("INVALID_CURSOR", codes.InvalidArgument, "invalid cursor")
+
+	// errInternal replaces every error that is not an Error, so internal
+	// details never reach the client.
+	errInternal = newError("INTERNAL", codes.Internal, "internal error")
+)
+
+func newError(reason string, code codes.Code, message string) *Error {
+	return &Error{Reason: reason, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is reports whether target is an Error with the same reason, so errors
+// with metadata still match the catalog variables.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Reason == e.Reason
+}
+
+// With returns a copy of e with the metadata key set to value.
+func (e *Error) With(key, value string) *Error {
+	copied := *e
+	copied.Metadata = maps.Clone(e.Metadata)
+	if copied.Metadata == nil {
+		copied.Metadata = make(map[string]string, 1)
+	}
+	copied.Metadata[key] = value
+	return &copied
+}
+
+// Status converts err for a client:
+//   - errors that already carry a gRPC status are kept,
+//   - context errors become Canceled or DeadlineExceeded,
+//   - an Error anywhere in the chain gives its code and ErrorInfo, with the
+//     message of the whole chain,
+//   - anything else is logged to logger and becomes a bare Internal error.
+func Status(logger *slog.Logger, err error) *status.Status {
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err)
+	}
+
+	var domainErr *Error
+	message := err.Error()
+	if !errors.As(err, &domainErr) {
+		logger.Error("internal error", "error", err)
+		domainErr = errInternal
+		message = errInternal.Message
+	}
+	st := status.New(domainErr.Code, message)
+	detailed, detailErr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   domainErr.Reason,
+		Domain:   ErrorDomain,
+		Metadata: domainErr.Metadata,
+	})
+	if detailErr != nil {
+		return st
+	}
+	return detailed
+}
@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatus(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		err      error
+		code     codes.Code
+		message  string
+		reason   string
+		metadata map[string]string
+		logged   bool
+	}{
+		{
+			name:     "wrapped domain error",
+			err:      fmt.Errorf("transferring funds: %w", ErrInsufficientFunds.With("account_id", "a1")),
+			code:     codes.FailedPrecondition,
+			message:  "transferring funds: insufficient funds",
+			reason:   "INSUFFICIENT_FUNDS",
+			metadata: map[string]string{"account_id": "a1"},
+		},
+		{
+			name:    "internal error",
+			err:     fmt.Errorf("listing transactions: %w", errors.New(`ERROR: relation "accounts" does not exist`)),
+			code:    codes.Internal,
+			message: "internal error",
+			reason:  "INTERNAL",
+			logged:  true,
+		},
+		{
+			name:    "status error",
+			err:     status.Error(codes.InvalidArgument, "invalid cursor"),
+			code:    codes.InvalidArgument,
+			message: "invalid cursor",
+		},
+		{
+			name:    "context error",
+			err:     fmt.Errorf("getting transaction: %w", context.DeadlineExceeded),
+			code:    codes.DeadlineExceeded,
+			message: "getting transaction: context deadline exceeded",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			st := Status(slog.New(slog.NewTextHandler(&logs, nil)), test.err)
+
+			if st.Code() != test.code || st.Message() != test.message {
+				t.Errorf("Status() = %s %q, want %s %q", st.Code(), st.Message(), test.code, test.message)
+			}
+			var info *errdetails.ErrorInfo
+			for _, detail := range st.Details() {
+				if i, ok := detail.(*errdetails.ErrorInfo); ok {
+					info = i
+				}
+			}
+			if info.GetReason() != test.reason {
+				t.Errorf("ErrorInfo reason = %q, want %q", info.GetReason(), test.reason)
+			}
+			if fmt.Sprint(info.GetMetadata()) != fmt.Sprint(test.metadata) {
+				t.Errorf("ErrorInfo metadata = %v, want %v", info.GetMetadata(), test.metadata)
+			}
+			if logged := strings.Contains(logs.String(), "relation"); logged != test.logged {
+				t.Errorf("internal details logged = %v, want %v: %q", logged, test.logged, logs.String())
+			}
+		})
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	err := fmt.Errorf("getting transaction: %w", ErrAccountNotFound.With("account_id", "a1"))
+	if !errors.Is(err, ErrAccountNotFound) {
+		t.Error("error with metadata does not match its catalog entry")
+	}
+	if errors.Is(err, ErrVersionConflict) {
+		t.Error("error matches a different catalog entry")
+	}
+	if ErrAccountNotFound.Metadata != nil {
+		t.Error("With modified the catalog entry")
+	}
+}
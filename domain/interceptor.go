@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"context"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/logging"
+	"google.golang.org/grpc"
+)
+
+// UnaryServerInterceptor converts the errors of handlers with Status, so
+// handlers can return domain and internal errors as they are.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		res, err := handler(ctx, req)
+		if err != nil {
+			return nil, Status(logging.FromContext(ctx), err).Err()
+		}
+		return res, nil
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming RPCs.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := handler(srv, stream); err != nil {
+			return Status(logging.FromContext(stream.Context()), err).Err()
+		}
+		return nil
+	}
+}
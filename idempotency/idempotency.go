@@ -0,0 +1,66 @@
+// Package idempotency remembers the outcome of mutating requests so a client
+// that retries with the same idempotency key gets the original response
+// instead of a second side effect.
+//
+// A key is first reserved with a hash of the request for a short lease. The
+// caller then runs the request and either completes the reservation with
+// the serialized response, which is kept for a much longer TTL, or releases
+// it on failure so the client may retry. Each reservation has its own
+// token, so a request that outlives its lease can neither overwrite nor
+// drop the reservation of a retry that took the key over.
+//
+// The reservation and the request do not share a transaction. If the
+// process dies, or storing the response fails, after the request took
+// effect, the key stays pending until the lease expires and a retry after
+// that applies the request again. The lease is therefore the window in
+// which a request can be duplicated, and must still be longer than any
+// request takes to run.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrNotReserved is returned by Complete when the key has no pending
+// reservation with the given token, e.g. because its lease expired while
+// the request was running and a retry reserved the key again.
+var ErrNotReserved = errors.New("idempotency key is not reserved")
+
+// Record is a stored idempotency key.
+type Record struct {
+	Key         string
+	RequestHash []byte
+	// Response is nil while the original request is still running.
+	Response  []byte
+	ExpiresAt time.Time
+	// Token identifies the reservation. It is only set on the record
+	// returned to the caller that reserved the key.
+	Token uuid.UUID
+}
+
+// Pending reports whether the original request has not finished yet.
+func (r Record) Pending() bool {
+	return r.Response == nil
+}
+
+// Store persists idempotency records. Implementations must be safe for
+// concurrent use and must let exactly one caller reserve a given key.
+type Store interface {
+	// Reserve claims key for a request with the given hash until lease has
+	// passed, and returns the new reservation with reserved set to true.
+	// If the key is already held by an unexpired record, that record is
+	// returned with reserved set to false.
+	Reserve(ctx context.Context, key string, requestHash []byte, lease time.Duration) (record Record, reserved bool, err error)
+	// Complete stores the response for the reservation of key with token
+	// and keeps it for ttl.
+	Complete(ctx context.Context, key string, token uuid.UUID, response []byte, ttl time.Duration) error
+	// Release drops the reservation of key with token, whose request
+	// failed. Other records of the key are left alone.
+	Release(ctx context.Context, key string, token uuid.UUID) error
+	// Purge deletes expired records and returns how many were removed.
+	Purge(ctx context.Context) (int64, error)
+}
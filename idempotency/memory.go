@@ -0,0 +1,78 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore is an in-process Store for tests and local development.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+	now     func() time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record), now: time.Now}
+}
+
+func (s *MemoryStore) Reserve(ctx context.Context, key string, requestHash []byte, lease time.Duration) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if existing, ok := s.records[key]; ok && now.Before(existing.ExpiresAt) {
+		existing.Token = uuid.Nil
+		return existing, false, nil
+	}
+	record := Record{Key: key, RequestHash: requestHash, ExpiresAt: now.Add(lease), Token: uuid.New()}
+	s.records[key] = record
+	return record, true, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, key string, token uuid.UUID, response []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[key]
+	if !ok || !record.Pending() || record.Token != token {
+		return ErrNotReserved
+	}
+	if response == nil {
+		response = []byte{}
+	}
+	record.Response = response
+	record.ExpiresAt = s.now().Add(ttl)
+	s.records[key] = record
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string, token uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok && record.Pending() && record.Token == token {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Purge(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var purged int64
+	for key, record := range s.records {
+		if !now.Before(record.ExpiresAt) {
+			delete(s.records, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
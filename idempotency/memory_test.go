@@ -0,0 +1,111 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	reservation, reserved, err := store.Reserve(ctx, "k", []byte("a"), time.Hour)
+	if err != nil || !reserved {
+		t.Fatalf("first Reserve = %v, %v; want reserved", reserved, err)
+	}
+
+	existing, reserved, err := store.Reserve(ctx, "k", []byte("a"), time.Hour)
+	if err != nil || reserved {
+		t.Fatalf("second Reserve = %v, %v; want existing record", reserved, err)
+	}
+	if !existing.Pending() {
+		t.Error("record should be pending before Complete")
+	}
+	if existing.Token == reservation.Token {
+		t.Error("existing record exposes the reservation token")
+	}
+
+	if err := store.Complete(ctx, "k", reservation.Token, []byte("response"), 2*time.Hour); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if err := store.Complete(ctx, "k", reservation.Token, []byte("again"), 2*time.Hour); !errors.Is(err, ErrNotReserved) {
+		t.Errorf("second Complete error = %v, want ErrNotReserved", err)
+	}
+	existing, _, _ = store.Reserve(ctx, "k", []byte("a"), time.Hour)
+	if string(existing.Response) != "response" {
+		t.Errorf("stored response = %q, want %q", existing.Response, "response")
+	}
+
+	// Released reservations can be taken again; completed ones cannot.
+	if err := store.Release(ctx, "k", reservation.Token); err != nil {
+		t.Fatal(err)
+	}
+	if _, reserved, _ := store.Reserve(ctx, "k", []byte("a"), time.Hour); reserved {
+		t.Error("Release dropped a completed record")
+	}
+	other, _, _ := store.Reserve(ctx, "other", []byte("b"), time.Hour)
+	store.Release(ctx, "other", other.Token)
+	if _, reserved, _ := store.Reserve(ctx, "other", []byte("b"), time.Hour); !reserved {
+		t.Error("released key could not be reserved again")
+	}
+
+	// A pending reservation only holds the key for its lease, while a
+	// completed one keeps its response for the TTL given to Complete.
+	now = now.Add(90 * time.Minute)
+	if _, reserved, _ := store.Reserve(ctx, "other", []byte("b"), time.Hour); !reserved {
+		t.Error("key with an expired lease could not be reserved again")
+	}
+	if _, reserved, _ := store.Reserve(ctx, "k", []byte("a"), time.Hour); reserved {
+		t.Error("completed key was reserved again before its TTL passed")
+	}
+
+	// Expired records are replaced on Reserve and removed by Purge.
+	now = now.Add(time.Hour)
+	if _, reserved, _ := store.Reserve(ctx, "k", []byte("c"), time.Hour); !reserved {
+		t.Error("expired key could not be reserved again")
+	}
+	if purged, err := store.Purge(ctx); err != nil || purged != 1 {
+		t.Errorf("Purge = %d, %v; want 1 expired record", purged, err)
+	}
+}
+
+func TestMemoryStoreLateCompletion(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	original, _, _ := store.Reserve(ctx, "k", []byte("a"), time.Minute)
+	// The original request outlives its lease and a retry takes the key
+	// over.
+	now = now.Add(2 * time.Minute)
+	retry, reserved, err := store.Reserve(ctx, "k", []byte("b"), time.Minute)
+	if err != nil || !reserved {
+		t.Fatalf("Reserve after the lease = %v, %v; want reserved", reserved, err)
+	}
+
+	// The original can neither complete nor release the retry's
+	// reservation.
+	if err := store.Complete(ctx, "k", original.Token, []byte("late"), time.Hour); !errors.Is(err, ErrNotReserved) {
+		t.Errorf("late Complete error = %v, want ErrNotReserved", err)
+	}
+	if err := store.Release(ctx, "k", original.Token); err != nil {
+		t.Fatal(err)
+	}
+	existing, reserved, _ := store.Reserve(ctx, "k", []byte("b"), time.Minute)
+	if reserved || !existing.Pending() || string(existing.RequestHash) != "b" {
+		t.Fatalf("late Release dropped or changed the retry's reservation: %+v, reserved %v", existing, reserved)
+	}
+
+	if err := store.Complete(ctx, "k", retry.Token, []byte("retry"), time.Hour); err != nil {
+		t.Fatalf("retry Complete: %v", err)
+	}
+	existing, _, _ = store.Reserve(ctx, "k", []byte("b"), time.Minute)
+	if string(existing.Response) != "retry" {
+		t.Errorf("stored response = %q, want the retry's", existing.Response)
+	}
+}
@@ -0,0 +1,85 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PgxStore keeps idempotency records in the idempotency_keys table.
+type PgxStore struct {
+	db *pgxpool.Pool
+}
+
+var _ Store = (*PgxStore)(nil)
+
+func NewPgxStore(db *pgxpool.Pool) *PgxStore {
+	return &PgxStore{db: db}
+}
+
+func (s *PgxStore) Reserve(ctx context.Context, key string, requestHash []byte, lease time.Duration) (Record, bool, error) {
+	// The existing record can be released between the failed insert and
+	// the lookup; in that case try to reserve again.
+	for attempt := 0; attempt < 3; attempt++ {
+		// An expired record is taken over in place, so keys can be reused
+		// once their lease or TTL has passed even before Purge runs.
+		reservation := Record{Key: key, RequestHash: requestHash, Token: uuid.New()}
+		err := s.db.QueryRow(ctx, `INSERT INTO idempotency_keys (key, request_hash, token, expires_at) VALUES ($1, $2, $3, now() + $4::INTERVAL)
+			ON CONFLICT (key) DO UPDATE SET request_hash = excluded.request_hash, response = NULL, token = excluded.token, created_at = now(), expires_at = excluded.expires_at
+			WHERE idempotency_keys.expires_at <= now()
+			RETURNING expires_at`, key, requestHash, reservation.Token, lease).
+			Scan(&reservation.ExpiresAt)
+		if err == nil {
+			return reservation, true, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return Record{}, false, err
+		}
+
+		existing := Record{Key: key}
+		err = s.db.QueryRow(ctx,
+			"SELECT request_hash, response, expires_at FROM idempotency_keys WHERE key = $1", key).
+			Scan(&existing.RequestHash, &existing.Response, &existing.ExpiresAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return Record{}, false, err
+		}
+		return existing, false, nil
+	}
+	return Record{}, false, errors.New("idempotency key is contended")
+}
+
+func (s *PgxStore) Complete(ctx context.Context, key string, token uuid.UUID, response []byte, ttl time.Duration) error {
+	if response == nil {
+		response = []byte{}
+	}
+	result, err := s.db.Exec(ctx,
+		"UPDATE idempotency_keys SET response = $3, expires_at = now() + $4::INTERVAL WHERE key = $1 AND token = $2 AND response IS NULL",
+		key, token, response, ttl)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotReserved
+	}
+	return nil
+}
+
+func (s *PgxStore) Release(ctx context.Context, key string, token uuid.UUID) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM idempotency_keys WHERE key = $1 AND token = $2 AND response IS NULL", key, token)
+	return err
+}
+
+func (s *PgxStore) Purge(ctx context.Context) (int64, error) {
+	result, err := s.db.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= now()")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,148 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/yaninyzwitty/golang-proj-with-db/tlsconfig"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// RequestIDHeader is the metadata key that carries the correlation ID of
+// an RPC, both from the client and back in the response headers.
+const RequestIDHeader = "x-request-id"
+
+// maxRequestIDLength bounds client-supplied IDs so they cannot bloat every
+// log line.
+const maxRequestIDLength = 128
+
+// UnaryServerInterceptor logs each RPC once it has finished, and gives the
+// handler a logger annotated with the request ID, method, peer and, for
+// mutual TLS, the verified client certificate.
+func UnaryServerInterceptor(base *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx, logger := begin(ctx, base, info.FullMethod)
+		res, err := handler(ctx, req)
+		size := 0
+		if m, ok := req.(proto.Message); ok {
+			size = proto.Size(m)
+		}
+		finish(ctx, logger, start, size, err)
+		return res, err
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming RPCs.
+// The logged request size is the total of all messages received.
+func StreamServerInterceptor(base *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, logger := begin(stream.Context(), base, info.FullMethod)
+		wrapped := &loggingStream{ServerStream: stream, ctx: ctx}
+		err := handler(srv, wrapped)
+		finish(ctx, logger, start, wrapped.received, err)
+		return err
+	}
+}
+
+func begin(ctx context.Context, base *slog.Logger, method string) (context.Context, *slog.Logger) {
+	requestID := incomingRequestID(ctx)
+	// Failing to set the header only loses the echo to the client; the ID
+	// is still logged.
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, requestID))
+
+	attrs := []any{"request_id", requestID, "method", method}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		attrs = append(attrs, "peer", p.Addr.String())
+	}
+	// Handlers can read the full identity with tlsconfig.PeerIdentity.
+	if identity, ok := tlsconfig.PeerIdentity(ctx); ok {
+		attrs = append(attrs, "client_cert", identity.Name())
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		attrs = append(attrs, "trace_id", span.TraceID().String())
+	}
+	logger := base.With(attrs...)
+	return NewContext(ctx, logger), logger
+}
+
+func finish(ctx context.Context, logger *slog.Logger, start time.Time, size int, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if serverFault(code) {
+		level = slog.LevelError
+	}
+	attrs := []any{
+		"code", code.String(),
+		"duration", time.Since(start),
+		"request_size", size,
+	}
+	if err != nil {
+		attrs = append(attrs, "error", status.Convert(err).Message())
+	}
+	logger.Log(ctx, level, "finished RPC", attrs...)
+}
+
+// serverFault reports whether code means the server, rather than the
+// request, is at fault.
+func serverFault(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unimplemented, codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// incomingRequestID returns the client's request ID if it is usable, or a
+// new one.
+func incomingRequestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(RequestIDHeader); len(values) > 0 && validRequestID(values[0]) {
+		return values[0]
+	}
+	return uuid.NewString()
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// loggingStream carries the request-scoped context and counts the bytes
+// received.
+type loggingStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	received int
+}
+
+func (s *loggingStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *loggingStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		if msg, ok := m.(proto.Message); ok {
+			s.received += proto.Size(msg)
+		}
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	var logs bytes.Buffer
+	logger, err := New(&logs, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptor(logger)))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	for _, test := range []struct {
+		name, sent string
+		propagated bool
+	}{
+		{"propagated", "req-123", true},
+		{"generated", "", false},
+		{"replaced", strings.Repeat("x", maxRequestIDLength+1), false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			logs.Reset()
+			ctx := context.Background()
+			if test.sent != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, RequestIDHeader, test.sent)
+			}
+			var header metadata.MD
+			if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header)); err != nil {
+				t.Fatal(err)
+			}
+
+			ids := header.Get(RequestIDHeader)
+			if len(ids) != 1 || ids[0] == "" {
+				t.Fatalf("response header %s = %q, want one ID", RequestIDHeader, ids)
+			}
+			if got := ids[0] == test.sent; got != test.propagated {
+				t.Errorf("response header %s = %q after sending %q", RequestIDHeader, ids[0], test.sent)
+			}
+
+			var entry map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatalf("decoding log entry %q: %v", logs.String(), err)
+			}
+			want := map[string]any{
+				"request_id": ids[0],
+				"method":     healthpb.Health_Check_FullMethodName,
+				"code":       "OK",
+				"peer":       "bufconn",
+			}
+			for key, value := range want {
+				if entry[key] != value {
+					t.Errorf("log field %s = %v, want %v", key, entry[key], value)
+				}
+			}
+			for _, key := range []string{"duration", "request_size"} {
+				if _, ok := entry[key]; !ok {
+					t.Errorf("log entry has no %s field: %s", key, logs.String())
+				}
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptorClientCertificate(t *testing.T) {
+	var logs bytes.Buffer
+	logger, err := New(&logs, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}}},
+	})
+
+	interceptor := UnaryServerInterceptor(logger)
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test/Method"}, func(ctx context.Context, req any) (any, error) {
+		FromContext(ctx).Info("handling")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2: %s", len(lines), logs.String())
+	}
+	for _, line := range lines {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry["client_cert"] != "billing" {
+			t.Errorf("log field client_cert = %v, want billing: %s", entry["client_cert"], line)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "loud", "text"); err == nil {
+		t.Error("New accepted an unknown level")
+	}
+	if _, err := New(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("New accepted an unknown format")
+	}
+}
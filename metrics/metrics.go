@@ -0,0 +1,107 @@
+// Package metrics exposes Prometheus metrics for RPCs, the database pool
+// and the transactions domain.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/yaninyzwitty/golang-proj-with-db/money"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics holds the collectors of one server. Its zero value is not
+// usable; create it with New.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	handled     *prometheus.CounterVec
+	handling    *prometheus.HistogramVec
+	transfers   *prometheus.CounterVec
+	transferred *prometheus.CounterVec
+}
+
+// New returns Metrics registered with a new registry, which also collects
+// the Go runtime and process metrics.
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_handled_total",
+			Help: "RPCs completed on the server, by method and status code.",
+		}, []string{"method", "code"}),
+		handling: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Time taken to complete RPCs on the server, by method and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+		transfers: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transactions_transfers_total",
+			Help: "Completed fund transfers, by the currency debited.",
+		}, []string{"currency"}),
+		transferred: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "transactions_transferred_minor_units_total",
+			Help: "Amount debited by completed fund transfers, in minor units of the currency.",
+		}, []string{"currency"}),
+	}
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.handled, m.handling, m.transfers, m.transferred,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
+
+// UnaryServerInterceptor counts and times unary RPCs.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+		m.observeRPC(info.FullMethod, start, err)
+		return res, err
+	}
+}
+
+// StreamServerInterceptor counts and times streaming RPCs over their whole
+// lifetime.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, stream)
+		m.observeRPC(info.FullMethod, start, err)
+		return err
+	}
+}
+
+func (m *Metrics) observeRPC(method string, start time.Time, err error) {
+	code := status.Code(err).String()
+	m.handled.WithLabelValues(method, code).Inc()
+	m.handling.WithLabelValues(method, code).Observe(time.Since(start).Seconds())
+}
+
+// ObserveTransfer records a completed transfer of amount.
+func (m *Metrics) ObserveTransfer(amount money.Money) {
+	m.transfers.WithLabelValues(amount.Currency).Inc()
+	m.transferred.WithLabelValues(amount.Currency).Add(float64(amount.Amount))
+}
+
+// RegisterOutboxLag exports lag, such as outbox.Relay.Lag, as the age of
+// the oldest undelivered outbox event.
+func (m *Metrics) RegisterOutboxLag(lag func() time.Duration) {
+	m.Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "outbox_lag_seconds",
+		Help: "Age of the oldest undelivered outbox event, or zero if the outbox is empty.",
+	}, func() float64 {
+		return lag().Seconds()
+	}))
+}
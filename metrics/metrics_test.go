@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/yaninyzwitty/golang-proj-with-db/money"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	m := New()
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/commerce.CommerceTransactions/GetTransaction"}
+	for _, err := range []error{nil, status.Error(codes.NotFound, "missing"), status.Error(codes.NotFound, "missing")} {
+		interceptor(context.Background(), nil, info, func(context.Context, any) (any, error) {
+			return nil, err
+		})
+	}
+
+	for code, want := range map[codes.Code]float64{codes.OK: 1, codes.NotFound: 2} {
+		got := testutil.ToFloat64(m.handled.WithLabelValues(info.FullMethod, code.String()))
+		if got != want {
+			t.Errorf("handled RPCs with code %s = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestObserveTransfer(t *testing.T) {
+	m := New()
+	m.ObserveTransfer(money.New("USD", 1250))
+	m.ObserveTransfer(money.New("USD", 50))
+	m.ObserveTransfer(money.New("JPY", 700))
+
+	if got := testutil.ToFloat64(m.transfers.WithLabelValues("USD")); got != 2 {
+		t.Errorf("USD transfers = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.transferred.WithLabelValues("USD")); got != 1300 {
+		t.Errorf("USD transferred = %v, want 1300", got)
+	}
+	if got := testutil.ToFloat64(m.transferred.WithLabelValues("JPY")); got != 700 {
+		t.Errorf("JPY transferred = %v, want 700", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+	m.RegisterOutboxLag(func() time.Duration { return 3 * time.Second })
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Result().Body)
+	if !strings.Contains(string(body), "outbox_lag_seconds 3\n") {
+		t.Errorf("metrics do not report the outbox lag:\n%s", body)
+	}
+}
@@ -0,0 +1,368 @@
+// Package migrations applies the numbered SQL files embedded under sql/ and
+// records which versions have run in the schema_migrations table.
+//
+// Files are named NNNN_description.up.sql and NNNN_description.down.sql.
+// Every migration runs in its own transaction together with the bookkeeping
+// row, and a lease in schema_migrations_lock keeps replicas that start at the
+// same time from applying the same version twice. The lease is renewed while
+// migrations run, however long they take. If it cannot be renewed before
+// another replica could take it over, e.g. because the database was
+// unreachable for most of LockTTL, the running migration is cancelled and
+// rolled back instead of racing the other replica.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	crdbpgx "github.com/cockroachdb/cockroach-go/v2/crdb/crdbpgxv5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+const lockID = 1
+
+// DB is the subset of *pgxpool.Pool the migrator needs. It must be safe for
+// concurrent use, as the lease is renewed while a migration runs, so a
+// single *pgx.Conn will not do.
+type DB interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// Migration is a single numbered schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a known migration has been applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         DB
+	migrations []Migration
+	owner      string
+
+	// LockTimeout bounds how long Up and Down wait for another replica to
+	// release the migration lock.
+	LockTimeout time.Duration
+	// LockTTL is how long a lock is honoured after its last renewal before
+	// it is considered abandoned by a crashed holder and may be taken over.
+	// The holder renews it every third of LockTTL.
+	LockTTL time.Duration
+}
+
+// New returns a Migrator for the embedded migrations.
+func New(db DB) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	return &Migrator{
+		db:          db,
+		migrations:  migrations,
+		owner:       fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		LockTimeout: time.Minute,
+		LockTTL:     5 * time.Minute,
+	}, nil
+}
+
+// Load parses the embedded migration files, sorted by version.
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name.up.sql or NNNN_name.down.sql", name)
+		}
+		number, description, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: missing description", name)
+		}
+		version, err := strconv.ParseInt(number, 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", name, number)
+		}
+
+		body, err := fs.ReadFile(files, path.Join("sql", name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: description}
+			byVersion[version] = m
+		} else if m.Name != description {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, description)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	ctx, unlock, err := m.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err := crdbpgx.ExecuteTx(ctx, m.db, pgx.TxOptions{}, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, migration.Up); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx,
+				"INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("applying migration %d_%s: %w", migration.Version, migration.Name, lockCause(ctx, err))
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down reverts the most recently applied migrations, at most steps of them,
+// and returns how many were reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	ctx, unlock, err := m.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == "" {
+			return count, fmt.Errorf("migration %d_%s cannot be reverted: no down file", migration.Version, migration.Name)
+		}
+		err := crdbpgx.ExecuteTx(ctx, m.db, pgx.TxOptions{}, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, migration.Down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("reverting migration %d_%s: %w", migration.Version, migration.Name, lockCause(ctx, err))
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Status reports every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, Status{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	if _, err := m.db.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT8 PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+	if _, err := m.db.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+		id INT8 PRIMARY KEY,
+		owner TEXT NOT NULL,
+		acquired_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("creating schema_migrations_lock: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	rows, err := m.db.Query(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// ErrLockLost is returned when the migration lease could not be renewed
+// and another replica may have taken it over.
+var ErrLockLost = errors.New("migration lock lost")
+
+// lock takes the migration lease, waiting up to LockTimeout for another
+// replica to release it, and renews it until unlock is called. Migrations
+// must run on the returned context, which is cancelled with ErrLockLost
+// if the lease is lost.
+func (m *Migrator) lock(ctx context.Context) (context.Context, func(), error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, m.LockTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		tag, err := m.db.Exec(waitCtx, `INSERT INTO schema_migrations_lock (id, owner, acquired_at) VALUES ($1, $2, now())
+			ON CONFLICT (id) DO UPDATE SET owner = excluded.owner, acquired_at = excluded.acquired_at
+			WHERE schema_migrations_lock.acquired_at < now() - $3::INTERVAL`, lockID, m.owner, m.LockTTL)
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return nil, nil, fmt.Errorf("acquiring migration lock: %w", err)
+		}
+		if err == nil && tag.RowsAffected() == 1 {
+			break
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return nil, nil, fmt.Errorf("timed out after %s waiting for migration lock", m.LockTimeout)
+		case <-ticker.C:
+		}
+	}
+
+	locked, lose := context.WithCancelCause(ctx)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		m.renew(locked, stop, lose)
+	}()
+
+	return locked, func() {
+		close(stop)
+		<-stopped
+		lose(nil)
+		// Release even if ctx was cancelled mid-migration so the next
+		// replica does not have to wait for the lease to expire.
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		_, _ = m.db.Exec(releaseCtx, "DELETE FROM schema_migrations_lock WHERE id = $1 AND owner = $2", lockID, m.owner)
+	}, nil
+}
+
+// renew extends the lease every third of LockTTL until stop is closed. It
+// calls lose with ErrLockLost once the lease has been taken over, or has
+// gone unrenewed for two thirds of LockTTL, leaving a margin for clock
+// differences before another replica may take it.
+func (m *Migrator) renew(ctx context.Context, stop <-chan struct{}, lose context.CancelCauseFunc) {
+	interval := m.LockTTL / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		tag, err := m.db.Exec(ctx, "UPDATE schema_migrations_lock SET acquired_at = now() WHERE id = $1 AND owner = $2", lockID, m.owner)
+		switch {
+		case err == nil && tag.RowsAffected() == 1:
+			renewed = time.Now()
+		case err == nil:
+			lose(ErrLockLost)
+			return
+		case time.Since(renewed) >= 2*interval:
+			lose(fmt.Errorf("%w: %v", ErrLockLost, err))
+			return
+		}
+	}
+}
+
+// lockCause returns the reason ctx was cancelled if the lease was lost,
+// and err otherwise.
+func lockCause(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); errors.Is(cause, ErrLockLost) {
+		return cause
+	}
+	return err
+}
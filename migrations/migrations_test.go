@@ -0,0 +1,106 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// lockDB answers the statements of the migration lock, renewing the lease
+// until it is taken over or renewals fail.
+type lockDB struct {
+	mu       sync.Mutex
+	renewals int
+	stolen   bool
+	failing  bool
+}
+
+func (db *lockDB) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	switch {
+	case strings.HasPrefix(sql, "UPDATE schema_migrations_lock"):
+		if db.failing {
+			return pgconn.CommandTag{}, errors.New("connection refused")
+		}
+		if db.stolen {
+			return pgconn.NewCommandTag("UPDATE 0"), nil
+		}
+		db.renewals++
+		return pgconn.NewCommandTag("UPDATE 1"), nil
+	case strings.HasPrefix(sql, "INSERT INTO schema_migrations_lock"):
+		return pgconn.NewCommandTag("INSERT 0 1"), nil
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+func (db *lockDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func (db *lockDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+func (db *lockDB) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+func (db *lockDB) set(f func(db *lockDB)) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	f(db)
+}
+
+func waitLost(t *testing.T, ctx context.Context) {
+	t.Helper()
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("lease loss did not cancel the migration context")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, ErrLockLost) {
+		t.Errorf("cause = %v, want ErrLockLost", cause)
+	}
+}
+
+func TestLockRenewsLease(t *testing.T) {
+	db := &lockDB{}
+	m := &Migrator{db: db, owner: "test", LockTimeout: time.Second, LockTTL: 30 * time.Millisecond}
+
+	ctx, unlock, err := m.lock(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Migrations outlive the TTL as long as the lease is renewed.
+	time.Sleep(100 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatalf("lease lost while renewals succeed: %v", context.Cause(ctx))
+	}
+	db.set(func(db *lockDB) {
+		if db.renewals < 2 {
+			t.Errorf("lease renewed %d times in 100ms with a 30ms TTL", db.renewals)
+		}
+		db.stolen = true
+	})
+	waitLost(t, ctx)
+	unlock()
+}
+
+func TestLockLostWhenRenewalsFail(t *testing.T) {
+	db := &lockDB{failing: true}
+	m := &Migrator{db: db, owner: "test", LockTimeout: time.Second, LockTTL: 30 * time.Millisecond}
+
+	ctx, unlock, err := m.lock(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	waitLost(t, ctx)
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS transactions;
+ALTER TABLE accounts DROP COLUMN IF EXISTS created_at;
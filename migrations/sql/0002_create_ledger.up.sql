@@ -0,0 +1,23 @@
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+-- A transaction groups the ledger entries of one balance change. The
+-- entries of a transaction always sum to zero: debits equal credits.
+CREATE TABLE IF NOT EXISTS transactions (
+    id UUID PRIMARY KEY,
+    kind TEXT NOT NULL CHECK (kind IN ('open', 'adjustment', 'transfer', 'close')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- account_id deliberately has no foreign key: entries outlive deleted
+-- accounts, and the external funding account only exists in the ledger.
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transaction_id UUID NOT NULL REFERENCES transactions (id),
+    account_id UUID NOT NULL,
+    direction TEXT NOT NULL CHECK (direction IN ('debit', 'credit')),
+    amount INT8 NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ledger_entries_account_id_idx ON ledger_entries (account_id, created_at);
+CREATE INDEX IF NOT EXISTS ledger_entries_transaction_id_idx ON ledger_entries (transaction_id);
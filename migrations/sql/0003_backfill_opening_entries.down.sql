@@ -0,0 +1,2 @@
+DELETE FROM ledger_entries WHERE transaction_id IN (SELECT id FROM accounts);
+DELETE FROM transactions WHERE id IN (SELECT id FROM accounts);
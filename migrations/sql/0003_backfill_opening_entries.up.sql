@@ -0,0 +1,13 @@
+-- CockroachDB does not allow schema changes after writes in the same
+-- transaction, so the backfill for 0002 lives in its own migration.
+UPDATE accounts SET balance = 0 WHERE balance IS NULL;
+
+-- Give every pre-existing balance an opening transaction against the
+-- external account so balances match their ledger entries.
+INSERT INTO transactions (id, kind)
+SELECT id, 'open' FROM accounts WHERE balance <> 0;
+
+INSERT INTO ledger_entries (transaction_id, account_id, direction, amount)
+SELECT id, id, CASE WHEN balance > 0 THEN 'credit' ELSE 'debit' END, abs(balance) FROM accounts WHERE balance <> 0
+UNION ALL
+SELECT id, '00000000-0000-0000-0000-000000000001'::UUID, CASE WHEN balance > 0 THEN 'debit' ELSE 'credit' END, abs(balance) FROM accounts WHERE balance <> 0;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS accounts_created_at_id_idx;
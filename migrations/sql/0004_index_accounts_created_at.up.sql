@@ -0,0 +1,2 @@
+-- Supports ListTransactions' keyset pagination over (created_at, id).
+CREATE INDEX IF NOT EXISTS accounts_created_at_id_idx ON accounts (created_at, id) INCLUDE (balance);
@@ -0,0 +1,2 @@
+ALTER TABLE ledger_entries DROP COLUMN IF EXISTS currency;
+ALTER TABLE accounts DROP COLUMN IF EXISTS currency;
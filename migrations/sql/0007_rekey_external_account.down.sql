@@ -0,0 +1,2 @@
+UPDATE ledger_entries SET account_id = '00000000-0000-0000-0000-000000000001'
+WHERE account_id = '85ec2fa8-0826-5836-9476-62a0810f8100';
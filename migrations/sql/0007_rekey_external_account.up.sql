@@ -0,0 +1,4 @@
+-- External accounts are now per currency. Move the legs posted against the
+-- single pre-currency external account to repository.ExternalAccountID("USD").
+UPDATE ledger_entries SET account_id = '85ec2fa8-0826-5836-9476-62a0810f8100'
+WHERE account_id = '00000000-0000-0000-0000-000000000001';
@@ -0,0 +1,3 @@
+-- Incremented on every change to an account, for optimistic concurrency
+-- control on UpdateTransaction.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS version INT8 NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+-- Lets WatchTransactions' polling source find accounts changed since a cursor.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
@@ -0,0 +1,14 @@
+-- Events written in the same transaction as the account change they
+-- describe. The server's relay delivers them and deletes each row once its
+-- sink has accepted it.
+CREATE TABLE IF NOT EXISTS outbox (
+    id UUID PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    aggregate_id UUID NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    attempts INT8 NOT NULL DEFAULT 0,
+    last_error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS outbox_created_at_idx ON outbox (created_at, id);
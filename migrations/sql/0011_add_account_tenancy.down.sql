@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS accounts_tenant_id_created_at_id_idx;
+ALTER TABLE accounts DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE accounts DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,7 @@
+-- Accounts created before tenancy belong to the default tenant '' and have
+-- no recorded owner.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS tenant_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS owner_id TEXT NOT NULL DEFAULT '';
+
+-- Supports ListTransactions' keyset pagination within one tenant.
+CREATE INDEX IF NOT EXISTS accounts_tenant_id_created_at_id_idx ON accounts (tenant_id, created_at, id) INCLUDE (balance);
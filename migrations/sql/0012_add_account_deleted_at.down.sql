@@ -0,0 +1,3 @@
+-- Accounts still soft-deleted when this is reverted become live again.
+DROP INDEX IF EXISTS accounts_deleted_at_idx;
+ALTER TABLE accounts DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,7 @@
+-- Deleted accounts keep their row, marked with the time of deletion, until
+-- the retention job purges them.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Lets the retention job find expired deletions without scanning live
+-- accounts.
+CREATE INDEX IF NOT EXISTS accounts_deleted_at_idx ON accounts (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE accounts DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_status_check;
+ALTER TABLE accounts DROP COLUMN IF EXISTS status;
@@ -0,0 +1,6 @@
+-- Lifecycle status of each account; only active accounts accept changes to
+-- their balance. Accounts opened before statuses existed are active.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'
+    CONSTRAINT accounts_status_check CHECK (status IN ('pending', 'active', 'frozen', 'closed'));
+-- Why the status last changed, as given by whoever changed it.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS status_reason TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS accounts_live_tenant_id_created_at_id_idx;
+DROP INDEX IF EXISTS accounts_live_created_at_id_idx;
+CREATE INDEX IF NOT EXISTS accounts_tenant_id_created_at_id_idx ON accounts (tenant_id, created_at, id) INCLUDE (balance);
+CREATE INDEX IF NOT EXISTS accounts_created_at_id_idx ON accounts (created_at, id) INCLUDE (balance);
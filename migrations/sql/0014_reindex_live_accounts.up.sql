@@ -0,0 +1,7 @@
+-- List reads every column, so the balance stored in the pagination indexes
+-- never saved a lookup of the row. Index only the live accounts List pages
+-- through instead.
+DROP INDEX IF EXISTS accounts_created_at_id_idx;
+DROP INDEX IF EXISTS accounts_tenant_id_created_at_id_idx;
+CREATE INDEX IF NOT EXISTS accounts_live_created_at_id_idx ON accounts (created_at, id) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS accounts_live_tenant_id_created_at_id_idx ON accounts (tenant_id, created_at, id) WHERE deleted_at IS NULL;
@@ -0,0 +1,3 @@
+-- Identifies each reservation of a key, so a request whose lease expired
+-- cannot complete or release the reservation of a retry that took over.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS token UUID;
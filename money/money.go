@@ -0,0 +1,98 @@
+// Package money represents amounts as integer minor units (e.g. cents) of an
+// ISO 4217 currency, so balances never pass through floating point.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownCurrency is returned for codes that are not active ISO 4217
+// currencies.
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// Money is an amount in the minor units of Currency.
+type Money struct {
+	Currency string
+	Amount   int64
+}
+
+func New(currency string, amount int64) Money {
+	return Money{Currency: currency, Amount: amount}
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+}
+
+// Validate reports whether the currency code is known.
+func (m Money) Validate() error {
+	return ValidateCurrency(m.Currency)
+}
+
+// ValidateCurrency reports whether code is an active ISO 4217 currency.
+// Codes are case sensitive and must be upper case.
+func ValidateCurrency(code string) error {
+	if _, ok := minorUnits[code]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownCurrency, code)
+	}
+	return nil
+}
+
+// MinorUnits returns the number of decimal places between the minor and
+// major unit of currency, e.g. 2 for USD and 0 for JPY.
+func MinorUnits(currency string) (int, error) {
+	units, ok := minorUnits[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w %q", ErrUnknownCurrency, currency)
+	}
+	return units, nil
+}
+
+// Parse reads a decimal amount in the major unit of currency, e.g. "12.34"
+// for 1234 cents. It accepts at most as many decimal places as the
+// currency has minor units.
+func Parse(currency, amount string) (Money, error) {
+	units, err := MinorUnits(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	digits, negative := strings.CutPrefix(amount, "-")
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" || strings.Trim(whole+fraction, "0123456789") != "" {
+		return Money{}, fmt.Errorf("invalid amount %q", amount)
+	}
+	if len(fraction) > units {
+		return Money{}, fmt.Errorf("amount %q has more than %d decimal places for %s", amount, units, currency)
+	}
+	minor, err := strconv.ParseInt(whole+fraction+strings.Repeat("0", units-len(fraction)), 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q: %w", amount, err)
+	}
+	if negative {
+		minor = -minor
+	}
+	return New(currency, minor), nil
+}
+
+// Decimal formats the amount in the major unit of its currency, e.g. "12.34"
+// for 1234 cents. Amounts in unknown currencies are formatted in minor
+// units.
+func (m Money) Decimal() string {
+	units, err := MinorUnits(m.Currency)
+	if err != nil || units == 0 {
+		return strconv.FormatInt(m.Amount, 10)
+	}
+
+	sign := ""
+	magnitude := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		magnitude = -magnitude
+	}
+	digits := fmt.Sprintf("%0*d", units+1, magnitude)
+	return sign + digits[:len(digits)-units] + "." + digits[len(digits)-units:]
+}
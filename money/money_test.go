@@ -0,0 +1,75 @@
+package money
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		currency, amount string
+		want             int64
+	}{
+		{"USD", "12.34", 1234},
+		{"USD", "12.3", 1230},
+		{"USD", "12", 1200},
+		{"USD", "-0.05", -5},
+		{"JPY", "500", 500},
+		{"BHD", "1.005", 1005},
+	} {
+		got, err := Parse(tc.currency, tc.amount)
+		if err != nil {
+			t.Errorf("Parse(%s, %q): %v", tc.currency, tc.amount, err)
+			continue
+		}
+		if got.Amount != tc.want {
+			t.Errorf("Parse(%s, %q) = %d, want %d", tc.currency, tc.amount, got.Amount, tc.want)
+		}
+		if back, _ := Parse(tc.currency, got.Decimal()); back != got {
+			t.Errorf("Parse(%s, %q) does not round-trip: %q", tc.currency, tc.amount, got.Decimal())
+		}
+	}
+
+	for _, tc := range []struct{ currency, amount string }{
+		{"USD", "1.234"},
+		{"JPY", "1.5"},
+		{"USD", ""},
+		{"USD", ".5"},
+		{"USD", "1e3"},
+		{"USD", "92233720368547758.08"},
+		{"XXX1", "1"},
+	} {
+		if _, err := Parse(tc.currency, tc.amount); err == nil {
+			t.Errorf("Parse(%s, %q) succeeded, want an error", tc.currency, tc.amount)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	for _, tc := range []struct {
+		m    Money
+		want string
+	}{
+		{New("USD", 1234), "12.34"},
+		{New("USD", 5), "0.05"},
+		{New("USD", -5), "-0.05"},
+		{New("JPY", 500), "500"},
+		{New("USD", math.MinInt64), "-92233720368547758.08"},
+	} {
+		if got := tc.m.Decimal(); got != tc.want {
+			t.Errorf("%v.Decimal() = %q, want %q", tc.m, got, tc.want)
+		}
+	}
+}
+
+func TestValidateCurrency(t *testing.T) {
+	if err := ValidateCurrency("EUR"); err != nil {
+		t.Errorf("ValidateCurrency(EUR): %v", err)
+	}
+	for _, code := range []string{"usd", "XYZ", ""} {
+		if err := New(code, 1).Validate(); !errors.Is(err, ErrUnknownCurrency) {
+			t.Errorf("Validate in %q error = %v, want ErrUnknownCurrency", code, err)
+		}
+	}
+}
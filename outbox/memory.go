@@ -0,0 +1,73 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore is an in-process Store, written to by
+// repository.MemoryRepository.
+type MemoryStore struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Append adds events to the end of the outbox.
+func (s *MemoryStore) Append(events ...Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for _, event := range events {
+		if event.CreatedAt.IsZero() {
+			event.CreatedAt = now
+		}
+		s.events = append(s.events, event)
+	}
+}
+
+func (s *MemoryStore) Pending(ctx context.Context, limit int) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.events)
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	return append([]Event(nil), s.events[:n]...), nil
+}
+
+func (s *MemoryStore) Delivered(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, event := range s.events {
+		if event.ID == id {
+			s.events = append(s.events[:i], s.events[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Failed(ctx context.Context, id uuid.UUID, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.events {
+		if s.events[i].ID == id {
+			s.events[i].Attempts++
+			break
+		}
+	}
+	return nil
+}
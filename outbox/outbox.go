@@ -0,0 +1,62 @@
+// Package outbox delivers account events that were recorded in the same
+// database transaction as the change they describe. A Relay reads pending
+// events from a Store and hands them to a Sink at least once, in order.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types written by the repository.
+const (
+	AccountCreated  = "account.created"
+	AccountUpdated  = "account.updated"
+	AccountDeleted  = "account.deleted"
+	AccountRestored = "account.restored"
+	// AccountStatusChanged is written when an account is activated,
+	// frozen, unfrozen or closed.
+	AccountStatusChanged = "account.status_changed"
+)
+
+// Event is one outbox row. ID is stable across redeliveries, so sinks can
+// use it to discard duplicates.
+type Event struct {
+	ID          uuid.UUID       `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID uuid.UUID       `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+	// Attempts counts earlier failed deliveries.
+	Attempts int64 `json:"-"`
+}
+
+// NewEvent builds an event for the account aggregateID with payload
+// encoded as JSON.
+func NewEvent(eventType string, aggregateID uuid.UUID, payload any) (Event, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{ID: uuid.New(), Type: eventType, AggregateID: aggregateID, Payload: raw}, nil
+}
+
+// Store holds events that have not been delivered yet.
+type Store interface {
+	// Pending returns up to limit undelivered events, oldest first.
+	Pending(ctx context.Context, limit int) ([]Event, error)
+	// Delivered removes an event once its sink has accepted it.
+	Delivered(ctx context.Context, id uuid.UUID) error
+	// Failed records an unsuccessful delivery attempt.
+	Failed(ctx context.Context, id uuid.UUID, reason string) error
+}
+
+// Sink is where a Relay delivers events. Deliver must only return nil once
+// the event is durably accepted; an error causes it to be retried.
+type Sink interface {
+	Deliver(ctx context.Context, event Event) error
+	Close() error
+}
@@ -0,0 +1,66 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PgxStore reads and acknowledges events in the outbox table. Events are
+// written by the repository through Write, inside its own transactions.
+//
+// Several relays may share one table; each event is then still delivered
+// at least once, but may be delivered by more than one of them.
+type PgxStore struct {
+	db *pgxpool.Pool
+}
+
+var _ Store = (*PgxStore)(nil)
+
+func NewPgxStore(db *pgxpool.Pool) *PgxStore {
+	return &PgxStore{db: db}
+}
+
+// Execer is satisfied by pgx.Tx.
+type Execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Write inserts events into the outbox as part of the caller's
+// transaction, so they are recorded if and only if it commits.
+func Write(ctx context.Context, tx Execer, events ...Event) error {
+	for _, event := range events {
+		if _, err := tx.Exec(ctx,
+			"INSERT INTO outbox (id, event_type, aggregate_id, payload) VALUES ($1, $2, $3, $4)",
+			event.ID, event.Type, event.AggregateID, event.Payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PgxStore) Pending(ctx context.Context, limit int) ([]Event, error) {
+	rows, err := s.db.Query(ctx,
+		"SELECT id, event_type, aggregate_id, payload, created_at, attempts FROM outbox ORDER BY created_at, id LIMIT $1", limit)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Event, error) {
+		var event Event
+		err := row.Scan(&event.ID, &event.Type, &event.AggregateID, &event.Payload, &event.CreatedAt, &event.Attempts)
+		return event, err
+	})
+}
+
+func (s *PgxStore) Delivered(ctx context.Context, id uuid.UUID) error {
+	_, err := s.db.Exec(ctx, "DELETE FROM outbox WHERE id = $1", id)
+	return err
+}
+
+func (s *PgxStore) Failed(ctx context.Context, id uuid.UUID, reason string) error {
+	_, err := s.db.Exec(ctx, "UPDATE outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1", id, reason)
+	return err
+}
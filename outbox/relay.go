@@ -0,0 +1,107 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// Relay moves events from a Store to a Sink. Events are delivered one at a
+// time in outbox order; a failed event is retried with exponential backoff
+// and holds back the events after it.
+type Relay struct {
+	store Store
+	sink  Sink
+	// interval is how often an empty outbox is polled, and the first
+	// backoff after a failure.
+	interval time.Duration
+
+	// BatchSize is how many events are read from the store at once.
+	BatchSize int
+	// MaxBackoff caps the wait between retries of a failing event.
+	MaxBackoff time.Duration
+
+	// lag is the age of the oldest undelivered event, in nanoseconds.
+	lag atomic.Int64
+}
+
+func NewRelay(store Store, sink Sink, interval time.Duration) *Relay {
+	return &Relay{
+		store:      store,
+		sink:       sink,
+		interval:   interval,
+		BatchSize:  100,
+		MaxBackoff: time.Minute,
+	}
+}
+
+// Lag returns how long the oldest undelivered event has been waiting, as
+// of the relay's last read of the store. It is zero when the outbox is
+// empty.
+func (r *Relay) Lag() time.Duration {
+	return time.Duration(r.lag.Load())
+}
+
+// Run delivers events until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	backoff := r.interval
+	for {
+		wait := r.interval
+		delivered, err := r.deliverBatch(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			slog.Error("failed to relay outbox events", "error", err, "lag", r.Lag(), "retry_in", backoff)
+			wait = backoff
+			backoff = min(backoff*2, r.MaxBackoff)
+		case delivered > 0:
+			backoff = r.interval
+			// More events may be waiting behind this batch.
+			wait = 0
+		default:
+			backoff = r.interval
+		}
+
+		// Jitter keeps relays of several servers from retrying in step.
+		if wait > 0 {
+			wait += rand.N(wait/2 + 1)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// deliverBatch delivers pending events until the batch is done or one
+// fails, and returns how many were delivered.
+func (r *Relay) deliverBatch(ctx context.Context) (int, error) {
+	events, err := r.store.Pending(ctx, r.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for i, event := range events {
+		r.lag.Store(int64(time.Since(event.CreatedAt)))
+		if err := r.sink.Deliver(ctx, event); err != nil {
+			if ctx.Err() == nil {
+				if failErr := r.store.Failed(ctx, event.ID, err.Error()); failErr != nil {
+					slog.Error("failed to record outbox delivery failure", "event_id", event.ID, "error", failErr)
+				}
+			}
+			return i, err
+		}
+		if err := r.store.Delivered(ctx, event.ID); err != nil {
+			// The sink has the event, so it will be delivered again;
+			// that is within the at-least-once contract.
+			return i, err
+		}
+	}
+	if len(events) < r.BatchSize {
+		r.lag.Store(0)
+	}
+	return len(events), nil
+}
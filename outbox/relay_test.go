@@ -0,0 +1,113 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/money"
+	"github.com/yaninyzwitty/golang-proj-with-db/outbox"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+)
+
+// flakySink fails its first failures deliveries and then forwards to next.
+type flakySink struct {
+	failures atomic.Int32
+	next     outbox.Sink
+}
+
+func (s *flakySink) Deliver(ctx context.Context, event outbox.Event) error {
+	if s.failures.Add(-1) >= 0 {
+		return errors.New("sink unavailable")
+	}
+	return s.next.Deliver(ctx, event)
+}
+
+func (s *flakySink) Close() error { return nil }
+
+func receive(t *testing.T, events <-chan outbox.Event) outbox.Event {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return outbox.Event{}
+	}
+}
+
+func TestRelay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := repository.NewMemoryRepository()
+	account, err := repo.Create(ctx, money.New("USD", 100), repository.StatusActive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.UpdateBalance(ctx, account.ID, money.New("USD", 40), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, account.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	delivered := make(chan outbox.Event)
+	sink := &flakySink{next: outbox.NewChannelSink(delivered)}
+	sink.failures.Store(2)
+	relay := outbox.NewRelay(repo.Outbox(), sink, time.Millisecond)
+	relay.MaxBackoff = 5 * time.Millisecond
+	go relay.Run(ctx)
+
+	// The first event is retried until the sink recovers, and nothing
+	// overtakes it.
+	for _, want := range []struct {
+		eventType string
+		balance   int64
+	}{
+		{outbox.AccountCreated, 100},
+		{outbox.AccountUpdated, 40},
+		{outbox.AccountDeleted, 0},
+	} {
+		event := receive(t, delivered)
+		if event.Type != want.eventType || event.AggregateID != account.ID {
+			t.Fatalf("got %s for %s, want %s for %s", event.Type, event.AggregateID, want.eventType, account.ID)
+		}
+		var payload struct {
+			Balance int64 `json:"balance"`
+		}
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			t.Fatal(err)
+		}
+		if payload.Balance != want.balance {
+			t.Fatalf("%s balance = %d, want %d", event.Type, payload.Balance, want.balance)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pending, err := repo.Outbox().Pending(ctx, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) == 0 && relay.Lag() == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d events still pending, lag %v", len(pending), relay.Lag())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestChannelSinkRespectsContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sink := outbox.NewChannelSink(make(chan outbox.Event))
+	cancel()
+	if err := sink.Deliver(ctx, outbox.Event{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Deliver without a consumer = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,11 @@
+package pb
+
+import _ "embed"
+
+// DescriptorSet is a serialized FileDescriptorSet of proto/hello.proto and
+// its imports, written by `make generate`. It lets reflection clients such
+// as grpcurl describe the service without the .proto sources; it can also
+// be passed to grpcurl directly with -protoset pb/descriptor.binpb.
+//
+//go:embed descriptor.binpb
+var DescriptorSet []byte
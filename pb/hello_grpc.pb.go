@@ -19,10 +19,15 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	CommerceTransactions_CreateTransaction_FullMethodName = "/commerce_transactions.CommerceTransactions/CreateTransaction"
-	CommerceTransactions_UpdateTransaction_FullMethodName = "/commerce_transactions.CommerceTransactions/UpdateTransaction"
-	CommerceTransactions_GetTransaction_FullMethodName    = "/commerce_transactions.CommerceTransactions/GetTransaction"
-	CommerceTransactions_DeleteTransaction_FullMethodName = "/commerce_transactions.CommerceTransactions/DeleteTransaction"
+	CommerceTransactions_CreateTransaction_FullMethodName    = "/commerce_transactions.CommerceTransactions/CreateTransaction"
+	CommerceTransactions_UpdateTransaction_FullMethodName    = "/commerce_transactions.CommerceTransactions/UpdateTransaction"
+	CommerceTransactions_GetTransaction_FullMethodName       = "/commerce_transactions.CommerceTransactions/GetTransaction"
+	CommerceTransactions_DeleteTransaction_FullMethodName    = "/commerce_transactions.CommerceTransactions/DeleteTransaction"
+	CommerceTransactions_RestoreTransaction_FullMethodName   = "/commerce_transactions.CommerceTransactions/RestoreTransaction"
+	CommerceTransactions_SetTransactionStatus_FullMethodName = "/commerce_transactions.CommerceTransactions/SetTransactionStatus"
+	CommerceTransactions_TransferFunds_FullMethodName        = "/commerce_transactions.CommerceTransactions/TransferFunds"
+	CommerceTransactions_ListTransactions_FullMethodName     = "/commerce_transactions.CommerceTransactions/ListTransactions"
+	CommerceTransactions_WatchTransactions_FullMethodName    = "/commerce_transactions.CommerceTransactions/WatchTransactions"
 )
 
 // CommerceTransactionsClient is the client API for CommerceTransactions service.
@@ -37,8 +42,18 @@ type CommerceTransactionsClient interface {
 	UpdateTransaction(ctx context.Context, in *UpdateTransactionRequest, opts ...grpc.CallOption) (*TransactionResponse, error)
 	// Retrieve details of a specific transaction
 	GetTransaction(ctx context.Context, in *GetTransactionRequest, opts ...grpc.CallOption) (*GetTransactionResponse, error)
-	// Delete a specific transaction
+	// Delete a specific transaction; it can be restored until it is purged
 	DeleteTransaction(ctx context.Context, in *DeleteTransactionRequest, opts ...grpc.CallOption) (*DeleteTransactionResponse, error)
+	// Undo the deletion of a transaction
+	RestoreTransaction(ctx context.Context, in *RestoreTransactionRequest, opts ...grpc.CallOption) (*TransactionResponse, error)
+	// Activate, freeze, unfreeze or close a transaction
+	SetTransactionStatus(ctx context.Context, in *SetTransactionStatusRequest, opts ...grpc.CallOption) (*TransactionResponse, error)
+	// Move funds between two accounts atomically
+	TransferFunds(ctx context.Context, in *TransferFundsRequest, opts ...grpc.CallOption) (*TransferFundsResponse, error)
+	// List transactions page by page, optionally filtered
+	ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*ListTransactionsResponse, error)
+	// Stream create, update and delete events for transactions
+	WatchTransactions(ctx context.Context, in *WatchTransactionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TransactionEvent], error)
 }
 
 type commerceTransactionsClient struct {
@@ -89,6 +104,65 @@ func (c *commerceTransactionsClient) DeleteTransaction(ctx context.Context, in *
 	return out, nil
 }
 
+func (c *commerceTransactionsClient) RestoreTransaction(ctx context.Context, in *RestoreTransactionRequest, opts ...grpc.CallOption) (*TransactionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransactionResponse)
+	err := c.cc.Invoke(ctx, CommerceTransactions_RestoreTransaction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *commerceTransactionsClient) SetTransactionStatus(ctx context.Context, in *SetTransactionStatusRequest, opts ...grpc.CallOption) (*TransactionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransactionResponse)
+	err := c.cc.Invoke(ctx, CommerceTransactions_SetTransactionStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *commerceTransactionsClient) TransferFunds(ctx context.Context, in *TransferFundsRequest, opts ...grpc.CallOption) (*TransferFundsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransferFundsResponse)
+	err := c.cc.Invoke(ctx, CommerceTransactions_TransferFunds_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *commerceTransactionsClient) ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*ListTransactionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTransactionsResponse)
+	err := c.cc.Invoke(ctx, CommerceTransactions_ListTransactions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *commerceTransactionsClient) WatchTransactions(ctx context.Context, in *WatchTransactionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TransactionEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CommerceTransactions_ServiceDesc.Streams[0], CommerceTransactions_WatchTransactions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchTransactionsRequest, TransactionEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CommerceTransactions_WatchTransactionsClient = grpc.ServerStreamingClient[TransactionEvent]
+
 // CommerceTransactionsServer is the server API for CommerceTransactions service.
 // All implementations must embed UnimplementedCommerceTransactionsServer
 // for forward compatibility.
@@ -101,8 +175,18 @@ type CommerceTransactionsServer interface {
 	UpdateTransaction(context.Context, *UpdateTransactionRequest) (*TransactionResponse, error)
 	// Retrieve details of a specific transaction
 	GetTransaction(context.Context, *GetTransactionRequest) (*GetTransactionResponse, error)
-	// Delete a specific transaction
+	// Delete a specific transaction; it can be restored until it is purged
 	DeleteTransaction(context.Context, *DeleteTransactionRequest) (*DeleteTransactionResponse, error)
+	// Undo the deletion of a transaction
+	RestoreTransaction(context.Context, *RestoreTransactionRequest) (*TransactionResponse, error)
+	// Activate, freeze, unfreeze or close a transaction
+	SetTransactionStatus(context.Context, *SetTransactionStatusRequest) (*TransactionResponse, error)
+	// Move funds between two accounts atomically
+	TransferFunds(context.Context, *TransferFundsRequest) (*TransferFundsResponse, error)
+	// List transactions page by page, optionally filtered
+	ListTransactions(context.Context, *ListTransactionsRequest) (*ListTransactionsResponse, error)
+	// Stream create, update and delete events for transactions
+	WatchTransactions(*WatchTransactionsRequest, grpc.ServerStreamingServer[TransactionEvent]) error
 	mustEmbedUnimplementedCommerceTransactionsServer()
 }
 
@@ -125,6 +209,21 @@ func (UnimplementedCommerceTransactionsServer) GetTransaction(context.Context, *
 func (UnimplementedCommerceTransactionsServer) DeleteTransaction(context.Context, *DeleteTransactionRequest) (*DeleteTransactionResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteTransaction not implemented")
 }
+func (UnimplementedCommerceTransactionsServer) RestoreTransaction(context.Context, *RestoreTransactionRequest) (*TransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreTransaction not implemented")
+}
+func (UnimplementedCommerceTransactionsServer) SetTransactionStatus(context.Context, *SetTransactionStatusRequest) (*TransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetTransactionStatus not implemented")
+}
+func (UnimplementedCommerceTransactionsServer) TransferFunds(context.Context, *TransferFundsRequest) (*TransferFundsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TransferFunds not implemented")
+}
+func (UnimplementedCommerceTransactionsServer) ListTransactions(context.Context, *ListTransactionsRequest) (*ListTransactionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTransactions not implemented")
+}
+func (UnimplementedCommerceTransactionsServer) WatchTransactions(*WatchTransactionsRequest, grpc.ServerStreamingServer[TransactionEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTransactions not implemented")
+}
 func (UnimplementedCommerceTransactionsServer) mustEmbedUnimplementedCommerceTransactionsServer() {}
 func (UnimplementedCommerceTransactionsServer) testEmbeddedByValue()                              {}
 
@@ -218,6 +317,89 @@ func _CommerceTransactions_DeleteTransaction_Handler(srv interface{}, ctx contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CommerceTransactions_RestoreTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreTransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommerceTransactionsServer).RestoreTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CommerceTransactions_RestoreTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommerceTransactionsServer).RestoreTransaction(ctx, req.(*RestoreTransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CommerceTransactions_SetTransactionStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetTransactionStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommerceTransactionsServer).SetTransactionStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CommerceTransactions_SetTransactionStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommerceTransactionsServer).SetTransactionStatus(ctx, req.(*SetTransactionStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CommerceTransactions_TransferFunds_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransferFundsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommerceTransactionsServer).TransferFunds(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CommerceTransactions_TransferFunds_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommerceTransactionsServer).TransferFunds(ctx, req.(*TransferFundsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CommerceTransactions_ListTransactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTransactionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommerceTransactionsServer).ListTransactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CommerceTransactions_ListTransactions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommerceTransactionsServer).ListTransactions(ctx, req.(*ListTransactionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CommerceTransactions_WatchTransactions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchTransactionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CommerceTransactionsServer).WatchTransactions(m, &grpc.GenericServerStream[WatchTransactionsRequest, TransactionEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CommerceTransactions_WatchTransactionsServer = grpc.ServerStreamingServer[TransactionEvent]
+
 // CommerceTransactions_ServiceDesc is the grpc.ServiceDesc for CommerceTransactions service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -241,7 +423,29 @@ var CommerceTransactions_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteTransaction",
 			Handler:    _CommerceTransactions_DeleteTransaction_Handler,
 		},
+		{
+			MethodName: "RestoreTransaction",
+			Handler:    _CommerceTransactions_RestoreTransaction_Handler,
+		},
+		{
+			MethodName: "SetTransactionStatus",
+			Handler:    _CommerceTransactions_SetTransactionStatus_Handler,
+		},
+		{
+			MethodName: "TransferFunds",
+			Handler:    _CommerceTransactions_TransferFunds_Handler,
+		},
+		{
+			MethodName: "ListTransactions",
+			Handler:    _CommerceTransactions_ListTransactions_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTransactions",
+			Handler:       _CommerceTransactions_WatchTransactions_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "hello.proto",
 }
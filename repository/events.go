@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yaninyzwitty/golang-proj-with-db/outbox"
+)
+
+// accountPayload is the JSON body of account outbox events. For deletions
+// it is the last state of the account, after any closing entry.
+type accountPayload struct {
+	ID           uuid.UUID `json:"id"`
+	Currency     string    `json:"currency"`
+	Balance      int64     `json:"balance"`
+	Version      int64     `json:"version"`
+	TenantID     string    `json:"tenant_id"`
+	OwnerID      string    `json:"owner_id"`
+	Status       Status    `json:"status"`
+	StatusReason string    `json:"status_reason,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	// DeletedAt is set on account.deleted events.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// accountEvents builds one outbox event of eventType per account.
+func accountEvents(eventType string, accounts ...Account) ([]outbox.Event, error) {
+	events := make([]outbox.Event, 0, len(accounts))
+	for _, account := range accounts {
+		payload := accountPayload{
+			ID:           account.ID,
+			Currency:     account.Currency,
+			Balance:      account.Balance,
+			Version:      account.Version,
+			TenantID:     account.TenantID,
+			OwnerID:      account.OwnerID,
+			Status:       account.Status,
+			StatusReason: account.StatusReason,
+			CreatedAt:    account.CreatedAt,
+			UpdatedAt:    account.UpdatedAt,
+		}
+		if !account.DeletedAt.IsZero() {
+			payload.DeletedAt = &account.DeletedAt
+		}
+		event, err := outbox.NewEvent(eventType, account.ID, payload)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
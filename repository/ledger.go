@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yaninyzwitty/golang-proj-with-db/money"
+)
+
+// externalNamespace seeds the per-currency external account IDs.
+var externalNamespace = uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+// ExternalAccountID returns the ledger-only account that funds opening
+// balances, absorbs adjustments and closures, and acts as the exchange
+// counterparty in cross-currency transfers for currency. It has no row in the
+// accounts table, and its balance is the negative of all money in that
+// currency held by customer accounts.
+func ExternalAccountID(currency string) uuid.UUID {
+	return uuid.NewSHA1(externalNamespace, []byte(currency))
+}
+
+// Direction is the side of the ledger an entry is posted to.
+type Direction string
+
+const (
+	Debit  Direction = "debit"
+	Credit Direction = "credit"
+)
+
+// TransactionKind records why a ledger transaction was posted.
+type TransactionKind string
+
+const (
+	KindOpen       TransactionKind = "open"
+	KindAdjustment TransactionKind = "adjustment"
+	KindTransfer   TransactionKind = "transfer"
+	KindClose      TransactionKind = "close"
+)
+
+// Entry is one leg of a ledger transaction. Credits increase an account's
+// balance and debits decrease it.
+type Entry struct {
+	ID            uuid.UUID
+	TransactionID uuid.UUID
+	Kind          TransactionKind
+	AccountID     uuid.UUID
+	Direction     Direction
+	Currency      string
+	Amount        int64
+	CreatedAt     time.Time
+}
+
+// Signed returns the entry's effect on its account's balance.
+func (e Entry) Signed() int64 {
+	if e.Direction == Debit {
+		return -e.Amount
+	}
+	return e.Amount
+}
+
+// Balance derives an account balance from its entries.
+func Balance(entries []Entry) int64 {
+	var balance int64
+	for _, entry := range entries {
+		balance += entry.Signed()
+	}
+	return balance
+}
+
+// leg is an entry that has not been posted yet.
+type leg struct {
+	account   uuid.UUID
+	direction Direction
+	currency  string
+	amount    int64
+}
+
+func (l leg) signed() int64 {
+	if l.direction == Debit {
+		return -l.amount
+	}
+	return l.amount
+}
+
+// moveLegs returns the two legs that move amount from one account to
+// another. A negative amount moves funds the other way.
+func moveLegs(from, to uuid.UUID, amount money.Money) []leg {
+	if amount.Amount < 0 {
+		from, to, amount.Amount = to, from, -amount.Amount
+	}
+	return []leg{
+		{account: from, direction: Debit, currency: amount.Currency, amount: amount.Amount},
+		{account: to, direction: Credit, currency: amount.Currency, amount: amount.Amount},
+	}
+}
+
+// transferLegs checks a transfer between source and destination and returns
+// its legs. Within one currency, amount moves directly. Across currencies,
+// converted is the amount credited to destination, and each side settles
+// against the external account of its own currency.
+func transferLegs(source, destination Account, amount money.Money, converted *money.Money) ([]leg, error) {
+	if amount.Currency != source.Currency {
+		return nil, fmt.Errorf("%w: account %s holds %s, transfer is in %s", ErrCurrencyMismatch, source.ID, source.Currency, amount.Currency)
+	}
+	if source.Balance < amount.Amount {
+		return nil, ErrInsufficientFunds.With("account_id", source.ID.String())
+	}
+
+	if destination.Currency == source.Currency {
+		if converted != nil && *converted != amount {
+			return nil, fmt.Errorf("%w: conversion given for a transfer within %s", ErrCurrencyMismatch, amount.Currency)
+		}
+		return moveLegs(source.ID, destination.ID, amount), nil
+	}
+
+	if converted == nil {
+		return nil, fmt.Errorf("%w: %s to %s needs a converted amount", ErrConversionRequired, source.Currency, destination.Currency)
+	}
+	if converted.Currency != destination.Currency {
+		return nil, fmt.Errorf("%w: account %s holds %s, converted amount is in %s", ErrCurrencyMismatch, destination.ID, destination.Currency, converted.Currency)
+	}
+	return append(
+		moveLegs(source.ID, ExternalAccountID(source.Currency), amount),
+		moveLegs(ExternalAccountID(destination.Currency), destination.ID, *converted)...,
+	), nil
+}
+
+// checkBalanced verifies that debits equal credits in every currency.
+func checkBalanced(legs []leg) error {
+	sums := make(map[string]int64)
+	for _, l := range legs {
+		if l.amount <= 0 {
+			return fmt.Errorf("ledger entry for %s has non-positive amount %d", l.account, l.amount)
+		}
+		sums[l.currency] += l.signed()
+	}
+	for currency, sum := range sums {
+		if sum != 0 {
+			return fmt.Errorf("ledger transaction is unbalanced by %d %s", sum, currency)
+		}
+	}
+	return nil
+}
+
+// isExternal reports whether l posts to an external account, which has no
+// row in the accounts table.
+func isExternal(l leg) bool {
+	return l.account == ExternalAccountID(l.currency)
+}
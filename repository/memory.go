@@ -0,0 +1,396 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yaninyzwitty/golang-proj-with-db/money"
+	"github.com/yaninyzwitty/golang-proj-with-db/outbox"
+)
+
+// MemoryRepository is an in-process AccountRepository for tests and local
+// development. Data is lost when the process exits.
+type MemoryRepository struct {
+	mu       sync.Mutex
+	accounts map[uuid.UUID]Account
+	entries  []Entry
+	outbox   *outbox.MemoryStore
+}
+
+var _ AccountRepository = (*MemoryRepository)(nil)
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{accounts: make(map[uuid.UUID]Account), outbox: outbox.NewMemoryStore()}
+}
+
+// Outbox returns the store that receives the repository's outbox events.
+func (r *MemoryRepository) Outbox() *outbox.MemoryStore {
+	return r.outbox
+}
+
+func (r *MemoryRepository) Create(ctx context.Context, balance money.Money, status Status) (Account, error) {
+	if err := balance.Validate(); err != nil {
+		return Account{}, err
+	}
+	if err := checkOpening(status); err != nil {
+		return Account{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Match the microsecond precision of TIMESTAMPTZ so cursors behave the
+	// same as against the database.
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	scope, _ := ScopeFrom(ctx)
+	account := Account{
+		ID:        uuid.New(),
+		Currency:  balance.Currency,
+		Version:   1,
+		TenantID:  scope.TenantID,
+		OwnerID:   scope.OwnerID,
+		Status:    status,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	tx := r.begin()
+	tx.put(account)
+	if balance.Amount != 0 {
+		if err := tx.post(KindOpen, moveLegs(ExternalAccountID(balance.Currency), account.ID, balance)); err != nil {
+			return Account{}, err
+		}
+	}
+	account = tx.get(account.ID)
+	if err := tx.emit(outbox.AccountCreated, account); err != nil {
+		return Account{}, err
+	}
+	tx.commit()
+	return account, nil
+}
+
+func (r *MemoryRepository) Get(ctx context.Context, id uuid.UUID) (Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[id]
+	if !ok || !live(ctx, account) {
+		return Account{}, ErrNotFound.With("account_id", id.String())
+	}
+	return account, nil
+}
+
+func (r *MemoryRepository) UpdateBalance(ctx context.Context, id uuid.UUID, balance money.Money, expectedVersion *int64) (Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[id]
+	if !ok || !live(ctx, account) {
+		return Account{}, ErrNotFound.With("account_id", id.String())
+	}
+	if err := checkActive(account); err != nil {
+		return Account{}, err
+	}
+	if expectedVersion != nil && *expectedVersion != account.Version {
+		return Account{}, ErrVersionConflict
+	}
+	if balance.Currency != account.Currency {
+		return Account{}, fmt.Errorf("%w: account %s holds %s, not %s", ErrCurrencyMismatch, id, account.Currency, balance.Currency)
+	}
+	tx := r.begin()
+	delta := balance.Amount - account.Balance
+	if delta == 0 {
+		account.Version++
+		account.UpdatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		tx.put(account)
+	} else if err := tx.post(KindAdjustment, moveLegs(ExternalAccountID(account.Currency), id, money.New(account.Currency, delta))); err != nil {
+		return Account{}, err
+	}
+	account = tx.get(id)
+	if err := tx.emit(outbox.AccountUpdated, account); err != nil {
+		return Account{}, err
+	}
+	tx.commit()
+	return account, nil
+}
+
+func (r *MemoryRepository) Delete(ctx context.Context, id uuid.UUID, force bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[id]
+	if !ok || !live(ctx, account) {
+		return ErrNotFound.With("account_id", id.String())
+	}
+	if account.Status == StatusFrozen {
+		return ErrFrozen.With("account_id", id.String())
+	}
+	tx := r.begin()
+	if account.Balance != 0 {
+		if !force {
+			return ErrBalanceNotZero.With("account_id", id.String())
+		}
+		if err := tx.post(KindClose, moveLegs(id, ExternalAccountID(account.Currency), account.Money())); err != nil {
+			return err
+		}
+	}
+	account = tx.get(id)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	account.DeletedAt, account.UpdatedAt = now, now
+	account.Version++
+	tx.put(account)
+	if err := tx.emit(outbox.AccountDeleted, account); err != nil {
+		return err
+	}
+	tx.commit()
+	return nil
+}
+
+func (r *MemoryRepository) Restore(ctx context.Context, id uuid.UUID) (Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[id]
+	if !ok || !visible(ctx, account) {
+		return Account{}, ErrNotFound.With("account_id", id.String())
+	}
+	if account.DeletedAt.IsZero() {
+		return Account{}, ErrNotDeleted.With("account_id", id.String())
+	}
+	account.DeletedAt = time.Time{}
+	account.UpdatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	account.Version++
+	tx := r.begin()
+	tx.put(account)
+	if err := tx.emit(outbox.AccountRestored, account); err != nil {
+		return Account{}, err
+	}
+	tx.commit()
+	return account, nil
+}
+
+func (r *MemoryRepository) PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for id, account := range r.accounts {
+		if !account.DeletedAt.IsZero() && account.DeletedAt.Before(cutoff) {
+			delete(r.accounts, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *MemoryRepository) SetStatus(ctx context.Context, id uuid.UUID, status Status, reason string) (Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[id]
+	if !ok || !live(ctx, account) {
+		return Account{}, ErrNotFound.With("account_id", id.String())
+	}
+	if err := checkTransition(account, status); err != nil {
+		return Account{}, err
+	}
+	account.Status, account.StatusReason = status, reason
+	account.UpdatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	account.Version++
+	tx := r.begin()
+	tx.put(account)
+	if err := tx.emit(outbox.AccountStatusChanged, account); err != nil {
+		return Account{}, err
+	}
+	tx.commit()
+	return account, nil
+}
+
+func (r *MemoryRepository) Transfer(ctx context.Context, from, to uuid.UUID, amount money.Money, converted *money.Money) (Account, Account, error) {
+	if err := validateTransfer(from, to, amount, converted); err != nil {
+		return Account{}, Account{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	source, ok := r.accounts[from]
+	if !ok || !live(ctx, source) {
+		return Account{}, Account{}, ErrNotFound.With("account_id", from.String())
+	}
+	destination, ok := r.accounts[to]
+	if !ok || !live(ctx, destination) {
+		return Account{}, Account{}, ErrNotFound.With("account_id", to.String())
+	}
+	if err := checkActive(source); err != nil {
+		return Account{}, Account{}, err
+	}
+	if err := checkActive(destination); err != nil {
+		return Account{}, Account{}, err
+	}
+	legs, err := transferLegs(source, destination, amount, converted)
+	if err != nil {
+		return Account{}, Account{}, err
+	}
+
+	tx := r.begin()
+	if err := tx.post(KindTransfer, legs); err != nil {
+		return Account{}, Account{}, err
+	}
+	source, destination = tx.get(from), tx.get(to)
+	if err := tx.emit(outbox.AccountUpdated, source, destination); err != nil {
+		return Account{}, Account{}, err
+	}
+	tx.commit()
+	return source, destination, nil
+}
+
+func (r *MemoryRepository) List(ctx context.Context, opts ListOptions) ([]Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	accounts := make([]Account, 0, len(r.accounts))
+	for _, account := range r.accounts {
+		if live(ctx, account) && matches(account, opts) {
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		less := compareCursor(CursorOf(accounts[i]), CursorOf(accounts[j])) < 0
+		if opts.Descending {
+			return !less
+		}
+		return less
+	})
+	if opts.Limit > 0 && len(accounts) > opts.Limit {
+		accounts = accounts[:opts.Limit]
+	}
+	return accounts, nil
+}
+
+func matches(account Account, opts ListOptions) bool {
+	if opts.Currency != "" && account.Currency != opts.Currency {
+		return false
+	}
+	if opts.MinBalance != nil && account.Balance < *opts.MinBalance {
+		return false
+	}
+	if opts.MaxBalance != nil && account.Balance > *opts.MaxBalance {
+		return false
+	}
+	if !opts.CreatedAfter.IsZero() && account.CreatedAt.Before(opts.CreatedAfter) {
+		return false
+	}
+	if !opts.CreatedBefore.IsZero() && !account.CreatedAt.Before(opts.CreatedBefore) {
+		return false
+	}
+	if opts.After != nil {
+		c := compareCursor(CursorOf(account), opts.After)
+		if (opts.Descending && c >= 0) || (!opts.Descending && c <= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func compareCursor(a, b *Cursor) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+func (r *MemoryRepository) Entries(ctx context.Context, accountID uuid.UUID) ([]Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var entries []Entry
+	for _, entry := range r.entries {
+		if entry.AccountID == accountID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// memoryTx stages the changes of one operation, including its outbox
+// events, so that like a database transaction either all of them are
+// applied by commit or none are. It is only used with r.mu held.
+type memoryTx struct {
+	r        *MemoryRepository
+	accounts map[uuid.UUID]Account
+	entries  []Entry
+	events   []outbox.Event
+}
+
+func (r *MemoryRepository) begin() *memoryTx {
+	return &memoryTx{r: r, accounts: make(map[uuid.UUID]Account)}
+}
+
+// get returns the account with its staged changes.
+func (tx *memoryTx) get(id uuid.UUID) Account {
+	if account, ok := tx.accounts[id]; ok {
+		return account
+	}
+	return tx.r.accounts[id]
+}
+
+func (tx *memoryTx) put(account Account) {
+	tx.accounts[account.ID] = account
+}
+
+// emit stages one outbox event per account.
+func (tx *memoryTx) emit(eventType string, accounts ...Account) error {
+	events, err := accountEvents(eventType, accounts...)
+	if err != nil {
+		return err
+	}
+	tx.events = append(tx.events, events...)
+	return nil
+}
+
+// post stages a balanced ledger transaction and applies each leg to its
+// account. The caller must have checked that every non-external account
+// exists.
+func (tx *memoryTx) post(kind TransactionKind, legs []leg) error {
+	if err := checkBalanced(legs); err != nil {
+		return err
+	}
+
+	transactionID := uuid.New()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for _, l := range legs {
+		tx.entries = append(tx.entries, Entry{
+			ID:            uuid.New(),
+			TransactionID: transactionID,
+			Kind:          kind,
+			AccountID:     l.account,
+			Direction:     l.direction,
+			Currency:      l.currency,
+			Amount:        l.amount,
+			CreatedAt:     now,
+		})
+		if isExternal(l) {
+			continue
+		}
+		account := tx.get(l.account)
+		account.Balance += l.signed()
+		account.Version++
+		account.UpdatedAt = now
+		tx.put(account)
+	}
+	return nil
+}
+
+// commit applies the staged changes.
+func (tx *memoryTx) commit() {
+	for id, account := range tx.accounts {
+		tx.r.accounts[id] = account
+	}
+	tx.r.entries = append(tx.r.entries, tx.entries...)
+	tx.r.outbox.Append(tx.events...)
+}
@@ -0,0 +1,14 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository/repotest"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repository.AccountRepository {
+		return repository.NewMemoryRepository()
+	})
+}
@@ -0,0 +1,407 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	crdbpgx "github.com/cockroachdb/cockroach-go/v2/crdb/crdbpgxv5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yaninyzwitty/golang-proj-with-db/money"
+	"github.com/yaninyzwitty/golang-proj-with-db/outbox"
+	"github.com/yaninyzwitty/golang-proj-with-db/tracing"
+)
+
+// PgxRepository is the CockroachDB-backed AccountRepository. Every change
+// also writes outbox events in the same transaction; read them with
+// outbox.PgxStore.
+type PgxRepository struct {
+	db *pgxpool.Pool
+}
+
+var _ AccountRepository = (*PgxRepository)(nil)
+
+func NewPgxRepository(db *pgxpool.Pool) *PgxRepository {
+	return &PgxRepository{db: db}
+}
+
+// executeTx runs fn in a transaction through crdbpgx.ExecuteTx, which
+// retries it on serialization failures. Each retry is recorded as an event
+// on the span of ctx.
+func executeTx(ctx context.Context, db *pgxpool.Pool, fn func(pgx.Tx) error) error {
+	attempt := 0
+	var previous error
+	return crdbpgx.ExecuteTx(ctx, db, pgx.TxOptions{}, func(tx pgx.Tx) error {
+		attempt++
+		tracing.RecordRetry(ctx, attempt, previous)
+		previous = fn(tx)
+		return previous
+	})
+}
+
+func (r *PgxRepository) Create(ctx context.Context, balance money.Money, status Status) (Account, error) {
+	if err := balance.Validate(); err != nil {
+		return Account{}, err
+	}
+	if err := checkOpening(status); err != nil {
+		return Account{}, err
+	}
+
+	var account Account
+	err := executeTx(ctx, r.db, func(tx pgx.Tx) error {
+		scope, _ := ScopeFrom(ctx)
+		id := uuid.New()
+		if _, err := tx.Exec(ctx,
+			"INSERT INTO accounts (id, currency, balance, tenant_id, owner_id, status) VALUES ($1, $2, 0, $3, $4, $5)",
+			id, balance.Currency, scope.TenantID, scope.OwnerID, status); err != nil {
+			return err
+		}
+		if balance.Amount != 0 {
+			if err := postTransaction(ctx, tx, KindOpen, moveLegs(ExternalAccountID(balance.Currency), id, balance)); err != nil {
+				return err
+			}
+		}
+		var err error
+		if account, err = getAccount(ctx, tx, id, ""); err != nil {
+			return err
+		}
+		return writeEvents(ctx, tx, outbox.AccountCreated, account)
+	})
+	if err != nil {
+		return Account{}, err
+	}
+	return account, nil
+}
+
+func (r *PgxRepository) Get(ctx context.Context, id uuid.UUID) (Account, error) {
+	return getAccount(ctx, r.db, id, "")
+}
+
+func (r *PgxRepository) UpdateBalance(ctx context.Context, id uuid.UUID, balance money.Money, expectedVersion *int64) (Account, error) {
+	var account Account
+	err := executeTx(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		account, err = getAccount(ctx, tx, id, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := checkActive(account); err != nil {
+			return err
+		}
+		// The row is locked, so the version cannot move between this check
+		// and the write below.
+		if expectedVersion != nil && *expectedVersion != account.Version {
+			return ErrVersionConflict
+		}
+		if balance.Currency != account.Currency {
+			return fmt.Errorf("%w: account %s holds %s, not %s", ErrCurrencyMismatch, id, account.Currency, balance.Currency)
+		}
+		if delta := balance.Amount - account.Balance; delta == 0 {
+			_, err = tx.Exec(ctx, "UPDATE accounts SET version = version + 1, updated_at = now() WHERE id = $1", id)
+		} else {
+			err = postTransaction(ctx, tx, KindAdjustment, moveLegs(ExternalAccountID(account.Currency), id, money.New(account.Currency, delta)))
+		}
+		if err != nil {
+			return err
+		}
+		if account, err = getAccount(ctx, tx, id, ""); err != nil {
+			return err
+		}
+		return writeEvents(ctx, tx, outbox.AccountUpdated, account)
+	})
+	if err != nil {
+		return Account{}, err
+	}
+	return account, nil
+}
+
+func (r *PgxRepository) Delete(ctx context.Context, id uuid.UUID, force bool) error {
+	return executeTx(ctx, r.db, func(tx pgx.Tx) error {
+		account, err := getAccount(ctx, tx, id, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if account.Status == StatusFrozen {
+			return ErrFrozen.With("account_id", id.String())
+		}
+		if account.Balance != 0 {
+			if !force {
+				return ErrBalanceNotZero.With("account_id", id.String())
+			}
+			if err := postTransaction(ctx, tx, KindClose, moveLegs(id, ExternalAccountID(account.Currency), account.Money())); err != nil {
+				return err
+			}
+		}
+		account, err = scanAccount(tx.QueryRow(ctx,
+			"UPDATE accounts SET deleted_at = now(), version = version + 1, updated_at = now() WHERE id = $1 RETURNING "+accountColumns, id))
+		if err != nil {
+			return err
+		}
+		return writeEvents(ctx, tx, outbox.AccountDeleted, account)
+	})
+}
+
+func (r *PgxRepository) Restore(ctx context.Context, id uuid.UUID) (Account, error) {
+	var account Account
+	err := executeTx(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		account, err = findAccount(ctx, tx, id, "", "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if account.DeletedAt.IsZero() {
+			return ErrNotDeleted.With("account_id", id.String())
+		}
+		account, err = scanAccount(tx.QueryRow(ctx,
+			"UPDATE accounts SET deleted_at = NULL, version = version + 1, updated_at = now() WHERE id = $1 RETURNING "+accountColumns, id))
+		if err != nil {
+			return err
+		}
+		return writeEvents(ctx, tx, outbox.AccountRestored, account)
+	})
+	if err != nil {
+		return Account{}, err
+	}
+	return account, nil
+}
+
+func (r *PgxRepository) PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.Exec(ctx, "DELETE FROM accounts WHERE deleted_at < $1", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+func (r *PgxRepository) SetStatus(ctx context.Context, id uuid.UUID, status Status, reason string) (Account, error) {
+	var account Account
+	err := executeTx(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		account, err = getAccount(ctx, tx, id, "FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := checkTransition(account, status); err != nil {
+			return err
+		}
+		account, err = scanAccount(tx.QueryRow(ctx,
+			"UPDATE accounts SET status = $2, status_reason = $3, version = version + 1, updated_at = now() WHERE id = $1 RETURNING "+accountColumns,
+			id, status, reason))
+		if err != nil {
+			return err
+		}
+		return writeEvents(ctx, tx, outbox.AccountStatusChanged, account)
+	})
+	if err != nil {
+		return Account{}, err
+	}
+	return account, nil
+}
+
+func (r *PgxRepository) Transfer(ctx context.Context, from, to uuid.UUID, amount money.Money, converted *money.Money) (Account, Account, error) {
+	if err := validateTransfer(from, to, amount, converted); err != nil {
+		return Account{}, Account{}, err
+	}
+
+	// executeTx retries the closure on serialization failures, so the
+	// balances are only captured from the attempt that commits.
+	var source, destination Account
+	err := executeTx(ctx, r.db, func(tx pgx.Tx) error {
+		// Lock both rows, always in ID order, so concurrent transfers queue
+		// instead of deadlocking and retrying.
+		locked := make(map[uuid.UUID]Account, 2)
+		for _, id := range orderedIDs(from, to) {
+			account, err := getAccount(ctx, tx, id, "FOR UPDATE")
+			if err != nil {
+				return err
+			}
+			if err := checkActive(account); err != nil {
+				return err
+			}
+			locked[id] = account
+		}
+		legs, err := transferLegs(locked[from], locked[to], amount, converted)
+		if err != nil {
+			return err
+		}
+		if err := postTransaction(ctx, tx, KindTransfer, legs); err != nil {
+			return err
+		}
+
+		if source, err = getAccount(ctx, tx, from, ""); err != nil {
+			return err
+		}
+		if destination, err = getAccount(ctx, tx, to, ""); err != nil {
+			return err
+		}
+		return writeEvents(ctx, tx, outbox.AccountUpdated, source, destination)
+	})
+	if err != nil {
+		return Account{}, Account{}, err
+	}
+	return source, destination, nil
+}
+
+func (r *PgxRepository) List(ctx context.Context, opts ListOptions) ([]Account, error) {
+	var conditions []string
+	var args []any
+	where := func(condition string, values ...any) {
+		placeholders := make([]any, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+
+	conditions = append(conditions, "deleted_at IS NULL")
+	if tenant, ok := tenantFilter(ctx); ok {
+		where("tenant_id = $%d", tenant)
+	}
+	if opts.Currency != "" {
+		where("currency = $%d", opts.Currency)
+	}
+	if opts.MinBalance != nil {
+		where("balance >= $%d", *opts.MinBalance)
+	}
+	if opts.MaxBalance != nil {
+		where("balance <= $%d", *opts.MaxBalance)
+	}
+	if !opts.CreatedAfter.IsZero() {
+		where("created_at >= $%d", opts.CreatedAfter)
+	}
+	if !opts.CreatedBefore.IsZero() {
+		where("created_at < $%d", opts.CreatedBefore)
+	}
+	// A row-value comparison lets CockroachDB seek straight to the cursor
+	// in the (created_at, id) index instead of scanning skipped rows.
+	direction := "ASC"
+	if opts.Descending {
+		direction = "DESC"
+	}
+	if opts.After != nil {
+		if opts.Descending {
+			where("(created_at, id) < ($%d, $%d)", opts.After.CreatedAt, opts.After.ID)
+		} else {
+			where("(created_at, id) > ($%d, $%d)", opts.After.CreatedAt, opts.After.ID)
+		}
+	}
+
+	query := "SELECT " + accountColumns + " FROM accounts WHERE " + strings.Join(conditions, " AND ")
+	query += fmt.Sprintf(" ORDER BY created_at %s, id %s", direction, direction)
+	if opts.Limit > 0 {
+		args = append(args, opts.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Account, error) {
+		return scanAccount(row)
+	})
+}
+
+func (r *PgxRepository) Entries(ctx context.Context, accountID uuid.UUID) ([]Entry, error) {
+	rows, err := r.db.Query(ctx, `SELECT e.id, e.transaction_id, t.kind, e.account_id, e.direction, e.currency, e.amount, e.created_at
+		FROM ledger_entries e JOIN transactions t ON t.id = e.transaction_id
+		WHERE e.account_id = $1 ORDER BY e.created_at, e.id`, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Entry, error) {
+		var entry Entry
+		err := row.Scan(&entry.ID, &entry.TransactionID, &entry.Kind, &entry.AccountID, &entry.Direction, &entry.Currency, &entry.Amount, &entry.CreatedAt)
+		return entry, err
+	})
+}
+
+// querier is satisfied by both *pgxpool.Pool and pgx.Tx.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+const accountColumns = "id, currency, balance, version, tenant_id, owner_id, status, status_reason, created_at, updated_at, deleted_at"
+
+func scanAccount(row pgx.Row) (Account, error) {
+	var account Account
+	var deletedAt *time.Time
+	err := row.Scan(&account.ID, &account.Currency, &account.Balance, &account.Version,
+		&account.TenantID, &account.OwnerID, &account.Status, &account.StatusReason, &account.CreatedAt, &account.UpdatedAt, &deletedAt)
+	if deletedAt != nil {
+		account.DeletedAt = *deletedAt
+	}
+	return account, err
+}
+
+// getAccount loads one live account visible in the scope of ctx; lock is
+// appended to the query, e.g. "FOR UPDATE" inside a transaction.
+func getAccount(ctx context.Context, q querier, id uuid.UUID, lock string) (Account, error) {
+	return findAccount(ctx, q, id, "AND deleted_at IS NULL", lock)
+}
+
+// findAccount is getAccount with the condition on deletion given by
+// deleted, which is empty to also find deleted accounts.
+func findAccount(ctx context.Context, q querier, id uuid.UUID, deleted, lock string) (Account, error) {
+	query := "SELECT " + accountColumns + " FROM accounts WHERE id = $1 " + deleted
+	args := []any{id}
+	if tenant, ok := tenantFilter(ctx); ok {
+		query += " AND tenant_id = $2"
+		args = append(args, tenant)
+	}
+	account, err := scanAccount(q.QueryRow(ctx, query+" "+lock, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Account{}, ErrNotFound.With("account_id", id.String())
+	}
+	if err != nil {
+		return Account{}, err
+	}
+	return account, nil
+}
+
+// writeEvents records one outbox event per account in tx.
+func writeEvents(ctx context.Context, tx pgx.Tx, eventType string, accounts ...Account) error {
+	events, err := accountEvents(eventType, accounts...)
+	if err != nil {
+		return err
+	}
+	return outbox.Write(ctx, tx, events...)
+}
+
+// postTransaction records a balanced ledger transaction and applies each leg
+// to the materialized balance and version of its account. Every
+// transaction has at most one leg per customer account.
+func postTransaction(ctx context.Context, tx pgx.Tx, kind TransactionKind, legs []leg) error {
+	if err := checkBalanced(legs); err != nil {
+		return err
+	}
+
+	transactionID := uuid.New()
+	if _, err := tx.Exec(ctx, "INSERT INTO transactions (id, kind) VALUES ($1, $2)", transactionID, kind); err != nil {
+		return err
+	}
+	for _, l := range legs {
+		if _, err := tx.Exec(ctx,
+			"INSERT INTO ledger_entries (transaction_id, account_id, direction, currency, amount) VALUES ($1, $2, $3, $4, $5)",
+			transactionID, l.account, l.direction, l.currency, l.amount); err != nil {
+			return err
+		}
+		if isExternal(l) {
+			continue
+		}
+		result, err := tx.Exec(ctx,
+			"UPDATE accounts SET balance = balance + $1, version = version + 1, updated_at = now() WHERE id = $2", l.signed(), l.account)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return ErrNotFound.With("account_id", l.account.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yaninyzwitty/golang-proj-with-db/migrations"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository/repotest"
+)
+
+// TestPgxRepository runs the conformance suite against the database in
+// TEST_DATABASE_URL. Its contents are deleted between subtests.
+func TestPgxRepository(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	migrator, err := migrations.New(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	repotest.Run(t, func(t *testing.T) repository.AccountRepository {
+		for _, table := range []string{"outbox", "ledger_entries", "transactions", "accounts"} {
+			if _, err := pool.Exec(ctx, "DELETE FROM "+table+" WHERE true"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return repository.NewPgxRepository(pool)
+	})
+}
@@ -0,0 +1,191 @@
+// Package repository is the storage boundary between the gRPC handlers and
+// the database. Handlers depend only on AccountRepository so they can be
+// exercised against MemoryRepository without a running CockroachDB.
+package repository
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yaninyzwitty/golang-proj-with-db/domain"
+	"github.com/yaninyzwitty/golang-proj-with-db/money"
+)
+
+// The repository's errors are the domain errors clients see, so handlers
+// can return them unchanged.
+var (
+	// ErrNotFound is returned when an account does not exist.
+	ErrNotFound = domain.ErrAccountNotFound
+	// ErrInsufficientFunds is returned when a transfer would overdraw the
+	// source account.
+	ErrInsufficientFunds = domain.ErrInsufficientFunds
+	// ErrInvalidAmount is returned for non-positive transfer amounts.
+	ErrInvalidAmount = domain.ErrInvalidAmount
+	// ErrSameAccount is returned when a transfer names the same account as
+	// source and destination.
+	ErrSameAccount = domain.ErrSameAccount
+	// ErrCurrencyMismatch is returned when an amount is not in the
+	// currency of the account it applies to.
+	ErrCurrencyMismatch = domain.ErrCurrencyMismatch
+	// ErrConversionRequired is returned for transfers between accounts in
+	// different currencies that do not say how much to credit.
+	ErrConversionRequired = domain.ErrConversionRequired
+	// ErrVersionConflict is returned when an update expected a version of
+	// the account that is no longer current.
+	ErrVersionConflict = domain.ErrVersionConflict
+	// ErrBalanceNotZero is returned when deleting an account that still
+	// holds funds without forcing it.
+	ErrBalanceNotZero = domain.ErrBalanceNotZero
+	// ErrNotDeleted is returned when restoring an account that is not
+	// deleted.
+	ErrNotDeleted = domain.ErrAccountNotDeleted
+	// ErrPending, ErrFrozen and ErrClosed are returned when changing the
+	// balance of an account in that status.
+	ErrPending = domain.ErrAccountPending
+	ErrFrozen  = domain.ErrAccountFrozen
+	ErrClosed  = domain.ErrAccountClosed
+	// ErrStatusTransition is returned when an account cannot change to the
+	// requested status from its current one.
+	ErrStatusTransition = domain.ErrStatusTransition
+)
+
+// Account is a balance-holding account. Balance is always equal to the sum
+// of the account's ledger entries.
+type Account struct {
+	ID uuid.UUID
+	// Currency is the ISO 4217 code the balance is held in. It never
+	// changes after the account is created.
+	Currency string
+	// Balance is in minor units of Currency.
+	Balance int64
+	// Version starts at 1 and is incremented by every change to the
+	// account, including transfers.
+	Version int64
+	// TenantID and OwnerID are taken from the Scope the account was
+	// created in.
+	TenantID string
+	OwnerID  string
+	Status   Status
+	// StatusReason is why the status last changed, as given to SetStatus.
+	StatusReason string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	// DeletedAt is when the account was deleted, or zero if it is live.
+	DeletedAt time.Time
+}
+
+// Money returns the account balance with its currency.
+func (a Account) Money() money.Money {
+	return money.New(a.Currency, a.Balance)
+}
+
+// Cursor is a position in the (created_at, id) ordering that List pages
+// through. It identifies the last account of the previous page.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// ListOptions filters and pages through accounts ordered by creation time,
+// with ties broken by ID.
+type ListOptions struct {
+	// Limit caps the number of accounts returned; zero means no limit.
+	Limit int
+	// After resumes listing after this position, in the direction given
+	// by Descending.
+	After *Cursor
+	// Descending lists the newest accounts first.
+	Descending bool
+
+	// Currency only returns accounts held in this currency, if set.
+	Currency string
+	// MinBalance and MaxBalance bound the balance inclusively.
+	MinBalance *int64
+	MaxBalance *int64
+	// CreatedAfter is an inclusive and CreatedBefore an exclusive bound on
+	// the creation time; the zero time leaves the bound open.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+// CursorOf returns the cursor positioned at account.
+func CursorOf(account Account) *Cursor {
+	return &Cursor{CreatedAt: account.CreatedAt, ID: account.ID}
+}
+
+// AccountRepository stores accounts and moves funds between them. Every
+// balance change is posted as a balanced ledger transaction in the same
+// database transaction that updates the account.
+// Implementations must be safe for concurrent use.
+//
+// Accounts outside the Scope of a call's context, and deleted accounts
+// except to Restore, are treated as if they did not exist: they are never
+// listed and are reported as ErrNotFound.
+type AccountRepository interface {
+	// Create opens an account in the currency of balance, funded from the
+	// external account. status must be StatusPending or StatusActive.
+	Create(ctx context.Context, balance money.Money, status Status) (Account, error)
+	Get(ctx context.Context, id uuid.UUID) (Account, error)
+	// UpdateBalance posts an adjustment against the external account that
+	// brings the balance to the given value, which must be in the
+	// account's currency. The account must be active. If expectedVersion
+	// is non-nil and the account is at a different version, nothing is
+	// changed and ErrVersionConflict is returned.
+	UpdateBalance(ctx context.Context, id uuid.UUID, balance money.Money, expectedVersion *int64) (Account, error)
+	// Delete marks the account deleted. An account that still holds funds
+	// is refused with ErrBalanceNotZero, unless force is set, in which
+	// case the balance is first returned to the external account. Frozen
+	// accounts are refused with ErrFrozen.
+	Delete(ctx context.Context, id uuid.UUID, force bool) error
+	// Restore undoes Delete and returns the account, with the balance it
+	// had when it was deleted. It returns ErrNotDeleted for live accounts
+	// and ErrNotFound once the account has been purged.
+	Restore(ctx context.Context, id uuid.UUID) (Account, error)
+	// PurgeDeleted permanently removes the accounts deleted before cutoff
+	// and returns how many it removed. Their ledger entries are kept. It
+	// is not scoped.
+	PurgeDeleted(ctx context.Context, cutoff time.Time) (int64, error)
+	// SetStatus changes the status of an account, recording reason, and
+	// returns the account. Changes that Status.CanBecome does not allow
+	// fail with ErrStatusTransition, and accounts holding funds cannot be
+	// closed: ErrBalanceNotZero.
+	SetStatus(ctx context.Context, id uuid.UUID, status Status, reason string) (Account, error)
+	// Transfer atomically moves amount, in the source account's currency,
+	// to another account and returns both accounts as they are after the
+	// transfer. Both accounts must be active. If the destination holds a
+	// different currency, converted is the amount it is credited and must
+	// be in its currency; otherwise converted must be nil or equal to
+	// amount.
+	Transfer(ctx context.Context, from, to uuid.UUID, amount money.Money, converted *money.Money) (Account, Account, error)
+	List(ctx context.Context, opts ListOptions) ([]Account, error)
+	// Entries returns the ledger entries posted to an account, oldest
+	// first, including those of deleted accounts. It is not scoped, as
+	// purged accounts no longer record their tenant.
+	Entries(ctx context.Context, accountID uuid.UUID) ([]Entry, error)
+}
+
+func validateTransfer(from, to uuid.UUID, amount money.Money, converted *money.Money) error {
+	if from == to {
+		return ErrSameAccount
+	}
+	if amount.Amount <= 0 || (converted != nil && converted.Amount <= 0) {
+		return ErrInvalidAmount
+	}
+	if err := amount.Validate(); err != nil {
+		return err
+	}
+	if converted != nil {
+		return converted.Validate()
+	}
+	return nil
+}
+
+// orderedIDs returns ids sorted ascending, the order rows are locked in.
+func orderedIDs(a, b uuid.UUID) []uuid.UUID {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		return []uuid.UUID{b, a}
+	}
+	return []uuid.UUID{a, b}
+}
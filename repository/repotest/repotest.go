@@ -0,0 +1,741 @@
+// Package repotest is the conformance suite every repository.AccountRepository
+// implementation must pass.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yaninyzwitty/golang-proj-with-db/money"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+)
+
+// Run exercises repo against the AccountRepository contract. newRepo is
+// called once per subtest and must return a repository with no accounts.
+func Run(t *testing.T, newRepo func(t *testing.T) repository.AccountRepository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo repository.AccountRepository)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"GetMissing", testGetMissing},
+		{"UpdateBalance", testUpdateBalance},
+		{"Delete", testDelete},
+		{"Restore", testRestore},
+		{"PurgeDeleted", testPurgeDeleted},
+		{"Status", testStatus},
+		{"InactiveAccounts", testInactiveAccounts},
+		{"Transfer", testTransfer},
+		{"TransferInsufficientFunds", testTransferInsufficientFunds},
+		{"TransferMissingAccount", testTransferMissingAccount},
+		{"TransferInvalid", testTransferInvalid},
+		{"ConcurrentTransfers", testConcurrentTransfers},
+		{"List", testList},
+		{"Ledger", testLedger},
+		{"Currencies", testCurrencies},
+		{"Versions", testVersions},
+		{"Tenancy", testTenancy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+func usd(amount int64) money.Money {
+	return money.New("USD", amount)
+}
+
+// create opens a USD account with the given balance.
+func create(t *testing.T, repo repository.AccountRepository, balance int64) repository.Account {
+	t.Helper()
+	return createIn(t, repo, usd(balance))
+}
+
+func createIn(t *testing.T, repo repository.AccountRepository, balance money.Money) repository.Account {
+	t.Helper()
+	account, err := repo.Create(context.Background(), balance, repository.StatusActive)
+	if err != nil {
+		t.Fatalf("Create(%s): %v", balance, err)
+	}
+	return account
+}
+
+func assertBalance(t *testing.T, repo repository.AccountRepository, id uuid.UUID, want int64) {
+	t.Helper()
+	account, err := repo.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", id, err)
+	}
+	if account.Balance != want {
+		t.Errorf("balance of %s = %d, want %d", id, account.Balance, want)
+	}
+}
+
+func assertLedgerBalanced(t *testing.T, repo repository.AccountRepository, accounts []uuid.UUID, currencies []string) {
+	t.Helper()
+	ids := append([]uuid.UUID(nil), accounts...)
+	for _, currency := range currencies {
+		ids = append(ids, repository.ExternalAccountID(currency))
+	}
+
+	type key struct {
+		transaction uuid.UUID
+		currency    string
+	}
+	sums := make(map[key]int64)
+	for _, id := range ids {
+		entries, err := repo.Entries(context.Background(), id)
+		if err != nil {
+			t.Fatalf("Entries(%s): %v", id, err)
+		}
+		for _, entry := range entries {
+			sums[key{entry.TransactionID, entry.Currency}] += entry.Signed()
+		}
+	}
+	for k, sum := range sums {
+		if sum != 0 {
+			t.Errorf("transaction %s is unbalanced by %d %s", k.transaction, sum, k.currency)
+		}
+	}
+}
+
+func testCreateAndGet(t *testing.T, repo repository.AccountRepository) {
+	account := create(t, repo, 500)
+	if account.ID == uuid.Nil {
+		t.Fatal("Create returned a nil ID")
+	}
+	if account.Balance != 500 {
+		t.Errorf("Create balance = %d, want 500", account.Balance)
+	}
+	if account.Currency != "USD" {
+		t.Errorf("Create currency = %q, want USD", account.Currency)
+	}
+	if account.Status != repository.StatusActive {
+		t.Errorf("Create status = %q, want active", account.Status)
+	}
+	if account.CreatedAt.IsZero() {
+		t.Error("Create returned a zero CreatedAt")
+	}
+	assertBalance(t, repo, account.ID, 500)
+}
+
+func testGetMissing(t *testing.T, repo repository.AccountRepository) {
+	_, err := repo.Get(context.Background(), uuid.New())
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testUpdateBalance(t *testing.T, repo repository.AccountRepository) {
+	ctx := context.Background()
+	account := create(t, repo, 100)
+
+	updated, err := repo.UpdateBalance(ctx, account.ID, usd(250), nil)
+	if err != nil {
+		t.Fatalf("UpdateBalance: %v", err)
+	}
+	if updated.Balance != 250 {
+		t.Errorf("UpdateBalance returned balance %d, want 250", updated.Balance)
+	}
+	assertBalance(t, repo, account.ID, 250)
+
+	if _, err := repo.UpdateBalance(ctx, uuid.New(), usd(1), nil); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("UpdateBalance(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testDelete(t *testing.T, repo repository.AccountRepository) {
+	ctx := context.Background()
+	account := create(t, repo, 100)
+	other := create(t, repo, 100)
+
+	if err := repo.Delete(ctx, account.ID, false); !errors.Is(err, repository.ErrBalanceNotZero) {
+		t.Fatalf("Delete with funds error = %v, want ErrBalanceNotZero", err)
+	}
+	assertBalance(t, repo, account.ID, 100)
+	if err := repo.Delete(ctx, account.ID, true); err != nil {
+		t.Fatalf("forced Delete: %v", err)
+	}
+	if _, err := repo.Get(ctx, account.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete(ctx, account.ID, true); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("second Delete error = %v, want ErrNotFound", err)
+	}
+	if _, err := repo.UpdateBalance(ctx, account.ID, usd(1), nil); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("UpdateBalance after Delete error = %v, want ErrNotFound", err)
+	}
+	if _, _, err := repo.Transfer(ctx, other.ID, account.ID, usd(1), nil); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Transfer to deleted account error = %v, want ErrNotFound", err)
+	}
+	if listed, err := repo.List(ctx, repository.ListOptions{}); err != nil || len(listed) != 1 || listed[0].ID != other.ID {
+		t.Errorf("List after Delete = %d accounts, %v; want only %s", len(listed), err, other.ID)
+	}
+}
+
+func testRestore(t *testing.T, repo repository.AccountRepository) {
+	ctx := context.Background()
+	account := create(t, repo, 0)
+
+	if _, err := repo.Restore(ctx, account.ID); !errors.Is(err, repository.ErrNotDeleted) {
+		t.Fatalf("Restore of live account error = %v, want ErrNotDeleted", err)
+	}
+	if err := repo.Delete(ctx, account.ID, false); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	restored, err := repo.Restore(ctx, account.ID)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if !restored.DeletedAt.IsZero() || restored.Version != account.Version+2 {
+		t.Errorf("restored account = %+v, want live at version %d", restored, account.Version+2)
+	}
+	if got, err := repo.Get(ctx, account.ID); err != nil || got.Version != restored.Version {
+		t.Errorf("Get after Restore = %+v, %v", got, err)
+	}
+	if _, err := repo.Restore(ctx, uuid.New()); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Restore(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testPurgeDeleted(t *testing.T, repo repository.AccountRepository) {
+	ctx := context.Background()
+	deleted := create(t, repo, 0)
+	kept := create(t, repo, 0)
+	if err := repo.Delete(ctx, deleted.ID, false); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if purged, err := repo.PurgeDeleted(ctx, time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+		t.Fatalf("PurgeDeleted before the deletion = %d, %v; want 0", purged, err)
+	}
+	if purged, err := repo.PurgeDeleted(ctx, time.Now().Add(time.Hour)); err != nil || purged != 1 {
+		t.Fatalf("PurgeDeleted after the deletion = %d, %v; want 1", purged, err)
+	}
+	if _, err := repo.Restore(ctx, deleted.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Restore after purge error = %v, want ErrNotFound", err)
+	}
+	assertBalance(t, repo, kept.ID, 0)
+}
+
+func testStatus(t *testing.T, repo repository.AccountRepository) {
+	ctx := context.Background()
+	if _, err := repo.Create(ctx, usd(0), repository.StatusFrozen); !errors.Is(err, repository.ErrStatusTransition) {
+		t.Errorf("Create(frozen) error = %v, want ErrStatusTransition", err)
+	}
+	account, err := repo.Create(ctx, usd(100), repository.StatusPending)
+	if err != nil {
+		t.Fatalf("Create(pending): %v", err)
+	}
+
+	for _, step := range []struct {
+		status  repository.Status
+		wantErr error
+	}{
+		{repository.StatusFrozen, repository.ErrStatusTransition},
+		{repository.StatusActive, nil},
+		{repository.StatusPending, repository.ErrStatusTransition},
+		{repository.StatusFrozen, nil},
+		{repository.StatusFrozen, repository.ErrStatusTransition},
+		{repository.StatusClosed, repository.ErrStatusTransition},
+		{repository.StatusActive, nil},
+		{repository.StatusClosed, repository.ErrBalanceNotZero},
+	} {
+		reason := "to " + string(step.status)
+		updated, err := repo.SetStatus(ctx, account.ID, step.status, reason)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("SetStatus(%s) from %s error = %v, want %v", step.status, account.Status, err, step.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		if updated.Status != step.status || updated.StatusReason != reason {
+			t.Errorf("SetStatus(%s) returned status %q with reason %q", step.status, updated.Status, updated.StatusReason)
+		}
+		if updated.Version != account.Version+1 {
+			t.Errorf("SetStatus(%s) version = %d, want %d", step.status, updated.Version, account.Version+1)
+		}
+		account = updated
+	}
+
+	if _, err := repo.UpdateBalance(ctx, account.ID, usd(0), nil); err != nil {
+		t.Fatalf("UpdateBalance: %v", err)
+	}
+	closed, err := repo.SetStatus(ctx, account.ID, repository.StatusClosed, "customer request")
+	if err != nil {
+		t.Fatalf("SetStatus(closed): %v", err)
+	}
+	if got, err := repo.Get(ctx, account.ID); err != nil || got.Status != repository.StatusClosed || got.StatusReason != "customer request" {
+		t.Errorf("Get after closing = %+v, %v", got, err)
+	}
+	for _, status := range []repository.Status{repository.StatusActive, repository.StatusFrozen, repository.StatusPending} {
+		if _, err := repo.SetStatus(ctx, closed.ID, status, "reopen"); !errors.Is(err, repository.ErrStatusTransition) {
+			t.Errorf("SetStatus(%s) of a closed account error = %v, want ErrStatusTransition", status, err)
+		}
+	}
+
+	if _, err := repo.SetStatus(ctx, uuid.New(), repository.StatusFrozen, "missing"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("SetStatus(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testInactiveAccounts(t *testing.T, repo repository.AccountRepository) {
+	ctx := context.Background()
+	active := create(t, repo, 100)
+	pending, err := repo.Create(ctx, usd(100), repository.StatusPending)
+	if err != nil {
+		t.Fatalf("Create(pending): %v", err)
+	}
+	frozen := create(t, repo, 100)
+	if _, err := repo.SetStatus(ctx, frozen.ID, repository.StatusFrozen, "suspicious activity"); err != nil {
+		t.Fatalf("SetStatus(frozen): %v", err)
+	}
+	closed := create(t, repo, 0)
+	if _, err := repo.SetStatus(ctx, closed.ID, repository.StatusClosed, "customer request"); err != nil {
+		t.Fatalf("SetStatus(closed): %v", err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		account repository.Account
+		wantErr error
+	}{
+		{"pending", pending, repository.ErrPending},
+		{"frozen", frozen, repository.ErrFrozen},
+		{"closed", closed, repository.ErrClosed},
+	} {
+		if _, err := repo.UpdateBalance(ctx, tt.account.ID, usd(50), nil); !errors.Is(err, tt.wantErr) {
+			t.Errorf("UpdateBalance(%s) error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if _, _, err := repo.Transfer(ctx, tt.account.ID, active.ID, usd(10), nil); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Transfer from %s error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if _, _, err := repo.Transfer(ctx, active.ID, tt.account.ID, usd(10), nil); !errors.Is(err, tt.wantErr) {
+			t.Errorf("Transfer to %s error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+	assertBalance(t, repo, active.ID, 100)
+	assertBalance(t, repo, pending.ID, 100)
+	assertBalance(t, repo, frozen.ID, 100)
+
+	if err := repo.Delete(ctx, frozen.ID, true); !errors.Is(err, repository.ErrFrozen) {
+		t.Errorf("Delete(frozen) error = %v, want ErrFrozen", err)
+	}
+	if err := repo.Delete(ctx, closed.ID, false); err != nil {
+		t.Errorf("Delete(closed): %v", err)
+	}
+}
+
+func testTransfer(t *testing.T, repo repository.AccountRepository) {
+	from := create(t, repo, 500)
+	to := create(t, repo, 100)
+
+	gotFrom, gotTo, err := repo.Transfer(context.Background(), from.ID, to.ID, usd(200), nil)
+	if err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	if gotFrom.Balance != 300 || gotTo.Balance != 300 {
+		t.Errorf("Transfer returned balances %d and %d, want 300 and 300", gotFrom.Balance, gotTo.Balance)
+	}
+	assertBalance(t, repo, from.ID, 300)
+	assertBalance(t, repo, to.ID, 300)
+}
+
+func testTransferInsufficientFunds(t *testing.T, repo repository.AccountRepository) {
+	from := create(t, repo, 50)
+	to := create(t, repo, 0)
+
+	_, _, err := repo.Transfer(context.Background(), from.ID, to.ID, usd(51), nil)
+	if !errors.Is(err, repository.ErrInsufficientFunds) {
+		t.Fatalf("Transfer error = %v, want ErrInsufficientFunds", err)
+	}
+	assertBalance(t, repo, from.ID, 50)
+	assertBalance(t, repo, to.ID, 0)
+}
+
+func testTransferMissingAccount(t *testing.T, repo repository.AccountRepository) {
+	ctx := context.Background()
+	account := create(t, repo, 100)
+
+	if _, _, err := repo.Transfer(ctx, uuid.New(), account.ID, usd(10), nil); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Transfer from missing account error = %v, want ErrNotFound", err)
+	}
+	if _, _, err := repo.Transfer(ctx, account.ID, uuid.New(), usd(10), nil); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Transfer to missing account error = %v, want ErrNotFound", err)
+	}
+	// A failed transfer must not leave the debit behind.
+	assertBalance(t, repo, account.ID, 100)
+}
+
+func testTransferInvalid(t *testing.T, repo repository.AccountRepository) {
+	ctx := context.Background()
+	from := create(t, repo, 100)
+	to := create(t, repo, 100)
+
+	if _, _, err := repo.Transfer(ctx, from.ID, from.ID, usd(10), nil); !errors.Is(err, repository.ErrSameAccount) {
+		t.Errorf("Transfer to self error = %v, want ErrSameAccount", err)
+	}
+	for _, amount := range []int64{0, -10} {
+		if _, _, err := repo.Transfer(ctx, from.ID, to.ID, usd(amount), nil); !errors.Is(err, repository.ErrInvalidAmount) {
+			t.Errorf("Transfer(%d) error = %v, want ErrInvalidAmount", amount, err)
+		}
+	}
+}
+
+func testConcurrentTransfers(t *testing.T, repo repository.AccountRepository) {
+	const workers = 8
+	const perWorker = 5
+	a := create(t, repo, workers*perWorker/2)
+	b := create(t, repo, workers*perWorker/2)
+
+	// Half the workers move funds each way; every transfer that would
+	// overdraw must fail cleanly and the total must be preserved.
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker)
+	for i := 0; i < workers; i++ {
+		from, to := a.ID, b.ID
+		if i%2 == 1 {
+			from, to = to, from
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				_, _, err := repo.Transfer(context.Background(), from, to, usd(1), nil)
+				if err != nil && !errors.Is(err, repository.ErrInsufficientFunds) {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent Transfer: %v", err)
+	}
+
+	gotA, err := repo.Get(context.Background(), a.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotB, err := repo.Get(context.Background(), b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total := gotA.Balance + gotB.Balance; total != workers*perWorker {
+		t.Errorf("total balance after concurrent transfers = %d, want %d", total, workers*perWorker)
+	}
+	if gotA.Balance < 0 || gotB.Balance < 0 {
+		t.Errorf("balances went negative: %d, %d", gotA.Balance, gotB.Balance)
+	}
+}
+
+func testList(t *testing.T, repo repository.AccountRepository) {
+	ctx := context.Background()
+	created := make(map[uuid.UUID]bool)
+	for i := 0; i < 5; i++ {
+		created[create(t, repo, int64(i*100)).ID] = true
+	}
+
+	for _, descending := range []bool{false, true} {
+		var seen []repository.Account
+		opts := repository.ListOptions{Limit: 2, Descending: descending}
+		for {
+			page, err := repo.List(ctx, opts)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if len(page) > opts.Limit {
+				t.Fatalf("List returned %d accounts, limit was %d", len(page), opts.Limit)
+			}
+			if len(page) == 0 {
+				break
+			}
+			seen = append(seen, page...)
+			opts.After = repository.CursorOf(page[len(page)-1])
+		}
+
+		if len(seen) != len(created) {
+			t.Fatalf("List(descending=%v) returned %d accounts in total, want %d", descending, len(seen), len(created))
+		}
+		for i, account := range seen {
+			if !created[account.ID] {
+				t.Errorf("List returned unknown account %s", account.ID)
+			}
+			if i == 0 {
+				continue
+			}
+			prev := seen[i-1]
+			ordered := prev.CreatedAt.Before(account.CreatedAt) ||
+				(prev.CreatedAt.Equal(account.CreatedAt) && prev.ID.String() < account.ID.String())
+			if descending {
+				ordered = account.CreatedAt.Before(prev.CreatedAt) ||
+					(account.CreatedAt.Equal(prev.CreatedAt) && account.ID.String() < prev.ID.String())
+			}
+			if !ordered {
+				t.Errorf("List(descending=%v) out of order at index %d", descending, i)
+			}
+		}
+	}
+
+	minBalance, maxBalance := int64(100), int64(300)
+	filtered, err := repo.List(ctx, repository.ListOptions{MinBalance: &minBalance, MaxBalance: &maxBalance})
+	if err != nil {
+		t.Fatalf("List with balance filter: %v", err)
+	}
+	if len(filtered) != 3 {
+		t.Errorf("List with balance in [100, 300] returned %d accounts, want 3", len(filtered))
+	}
+	for _, account := range filtered {
+		if account.Balance < minBalance || account.Balance > maxBalance {
+			t.Errorf("List with balance filter returned balance %d", account.Balance)
+		}
+	}
+
+	all, err := repo.List(ctx, repository.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pivot := all[2].CreatedAt
+	before, err := repo.List(ctx, repository.ListOptions{CreatedBefore: pivot})
+	if err != nil {
+		t.Fatalf("List with CreatedBefore: %v", err)
+	}
+	after, err := repo.List(ctx, repository.ListOptions{CreatedAfter: pivot})
+	if err != nil {
+		t.Fatalf("List with CreatedAfter: %v", err)
+	}
+	if len(before)+len(after) != len(all) {
+		t.Errorf("CreatedBefore and CreatedAfter split %d accounts into %d and %d", len(all), len(before), len(after))
+	}
+	for _, account := range before {
+		if !account.CreatedAt.Before(pivot) {
+			t.Errorf("CreatedBefore returned account created at %v, pivot %v", account.CreatedAt, pivot)
+		}
+	}
+}
+
+func testLedger(t *testing.T, repo repository.AccountRepository) {
+	ctx := context.Background()
+	a := create(t, repo, 100)
+	b := create(t, repo, 0)
+
+	if _, err := repo.UpdateBalance(ctx, a.ID, usd(40), nil); err != nil {
+		t.Fatalf("UpdateBalance: %v", err)
+	}
+	if _, _, err := repo.Transfer(ctx, a.ID, b.ID, usd(15), nil); err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	if _, _, err := repo.Transfer(ctx, a.ID, b.ID, usd(1000), nil); !errors.Is(err, repository.ErrInsufficientFunds) {
+		t.Fatalf("overdrawing Transfer error = %v, want ErrInsufficientFunds", err)
+	}
+
+	entriesA, err := repo.Entries(ctx, a.ID)
+	if err != nil {
+		t.Fatalf("Entries: %v", err)
+	}
+	wantKinds := []repository.TransactionKind{repository.KindOpen, repository.KindAdjustment, repository.KindTransfer}
+	if len(entriesA) != len(wantKinds) {
+		t.Fatalf("account has %d entries, want %d", len(entriesA), len(wantKinds))
+	}
+	for i, entry := range entriesA {
+		if entry.Kind != wantKinds[i] {
+			t.Errorf("entry %d kind = %s, want %s", i, entry.Kind, wantKinds[i])
+		}
+	}
+
+	// Balances must be derivable from the ledger.
+	for _, id := range []uuid.UUID{a.ID, b.ID} {
+		account, err := repo.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := repo.Entries(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := repository.Balance(entries); got != account.Balance {
+			t.Errorf("ledger balance of %s = %d, account balance = %d", id, got, account.Balance)
+		}
+	}
+
+	// Closing an account with funds posts the remainder back to the
+	// external account and keeps the history.
+	if err := repo.Delete(ctx, b.ID, true); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	entriesB, err := repo.Entries(ctx, b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entriesB) != 2 || entriesB[1].Kind != repository.KindClose {
+		t.Fatalf("deleted account entries = %+v, want transfer then close", entriesB)
+	}
+	if got := repository.Balance(entriesB); got != 0 {
+		t.Errorf("ledger balance of deleted account = %d, want 0", got)
+	}
+
+	// Every transaction touching these accounts must balance once the
+	// external legs are included.
+	assertLedgerBalanced(t, repo, []uuid.UUID{a.ID, b.ID}, []string{"USD"})
+}
+
+func testCurrencies(t *testing.T, repo repository.AccountRepository) {
+	ctx := context.Background()
+	if _, err := repo.Create(ctx, money.New("XYZ", 1), repository.StatusActive); !errors.Is(err, money.ErrUnknownCurrency) {
+		t.Errorf("Create in unknown currency error = %v, want ErrUnknownCurrency", err)
+	}
+
+	dollars := createIn(t, repo, money.New("USD", 1000))
+	euros := createIn(t, repo, money.New("EUR", 0))
+	moreDollars := createIn(t, repo, money.New("USD", 0))
+
+	if _, err := repo.UpdateBalance(ctx, dollars.ID, money.New("EUR", 5), nil); !errors.Is(err, repository.ErrCurrencyMismatch) {
+		t.Errorf("UpdateBalance in another currency error = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, _, err := repo.Transfer(ctx, dollars.ID, euros.ID, money.New("EUR", 10), nil); !errors.Is(err, repository.ErrCurrencyMismatch) {
+		t.Errorf("Transfer not in source currency error = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, _, err := repo.Transfer(ctx, dollars.ID, euros.ID, usd(100), nil); !errors.Is(err, repository.ErrConversionRequired) {
+		t.Errorf("cross-currency Transfer without conversion error = %v, want ErrConversionRequired", err)
+	}
+	wrong := money.New("GBP", 90)
+	if _, _, err := repo.Transfer(ctx, dollars.ID, euros.ID, usd(100), &wrong); !errors.Is(err, repository.ErrCurrencyMismatch) {
+		t.Errorf("Transfer converted to wrong currency error = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, _, err := repo.Transfer(ctx, dollars.ID, moreDollars.ID, usd(100), &wrong); !errors.Is(err, repository.ErrCurrencyMismatch) {
+		t.Errorf("same-currency Transfer with a conversion error = %v, want ErrCurrencyMismatch", err)
+	}
+	assertBalance(t, repo, dollars.ID, 1000)
+
+	converted := money.New("EUR", 92)
+	from, to, err := repo.Transfer(ctx, dollars.ID, euros.ID, usd(100), &converted)
+	if err != nil {
+		t.Fatalf("cross-currency Transfer: %v", err)
+	}
+	if from.Balance != 900 || to.Balance != 92 {
+		t.Errorf("cross-currency Transfer returned balances %d and %d, want 900 and 92", from.Balance, to.Balance)
+	}
+	assertBalance(t, repo, dollars.ID, 900)
+	assertBalance(t, repo, euros.ID, 92)
+	assertLedgerBalanced(t, repo, []uuid.UUID{dollars.ID, euros.ID}, []string{"USD", "EUR"})
+
+	onlyEuros, err := repo.List(ctx, repository.ListOptions{Currency: "EUR"})
+	if err != nil {
+		t.Fatalf("List by currency: %v", err)
+	}
+	if len(onlyEuros) != 1 || onlyEuros[0].ID != euros.ID {
+		t.Errorf("List(Currency: EUR) = %+v, want only %s", onlyEuros, euros.ID)
+	}
+}
+
+func testVersions(t *testing.T, repo repository.AccountRepository) {
+	ctx := context.Background()
+	account := create(t, repo, 100)
+	other := create(t, repo, 0)
+
+	current := account.Version
+	stale := current
+	updated, err := repo.UpdateBalance(ctx, account.ID, usd(150), &current)
+	if err != nil {
+		t.Fatalf("UpdateBalance at current version: %v", err)
+	}
+	if updated.Version <= current {
+		t.Errorf("UpdateBalance version = %d, want greater than %d", updated.Version, current)
+	}
+
+	if _, err := repo.UpdateBalance(ctx, account.ID, usd(999), &stale); !errors.Is(err, repository.ErrVersionConflict) {
+		t.Errorf("UpdateBalance at stale version error = %v, want ErrVersionConflict", err)
+	}
+	assertBalance(t, repo, account.ID, 150)
+
+	// Writing the same balance is still a write.
+	same, err := repo.UpdateBalance(ctx, account.ID, usd(150), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Version <= updated.Version {
+		t.Errorf("no-op UpdateBalance version = %d, want greater than %d", same.Version, updated.Version)
+	}
+
+	// Transfers move both accounts' versions, so they invalidate
+	// concurrent readers too.
+	from, to, err := repo.Transfer(ctx, account.ID, other.ID, usd(10), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from.Version <= same.Version || to.Version <= other.Version {
+		t.Errorf("Transfer versions = %d, %d; want greater than %d, %d", from.Version, to.Version, same.Version, other.Version)
+	}
+	got, err := repo.Get(ctx, account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != from.Version {
+		t.Errorf("Get version = %d, Transfer returned %d", got.Version, from.Version)
+	}
+}
+
+func testTenancy(t *testing.T, repo repository.AccountRepository) {
+	ctx := context.Background()
+	acme := repository.WithScope(ctx, repository.Scope{TenantID: "acme", OwnerID: "alice"})
+	globex := repository.WithScope(ctx, repository.Scope{TenantID: "globex", OwnerID: "bob"})
+	support := repository.WithScope(ctx, repository.Scope{TenantID: "ops", AllTenants: true})
+
+	a, err := repo.Create(acme, usd(100), repository.StatusActive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.TenantID != "acme" || a.OwnerID != "alice" {
+		t.Fatalf("created account has tenant %q and owner %q, want acme and alice", a.TenantID, a.OwnerID)
+	}
+	b, err := repo.Create(globex, usd(100), repository.StatusActive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := repo.Get(acme, a.ID); err != nil || got.TenantID != "acme" {
+		t.Fatalf("Get in own tenant = %+v, %v", got, err)
+	}
+	if _, err := repo.Get(acme, b.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("Get across tenants: got %v, want ErrNotFound", err)
+	}
+	if _, err := repo.UpdateBalance(acme, b.ID, usd(0), nil); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("UpdateBalance across tenants: got %v, want ErrNotFound", err)
+	}
+	if _, _, err := repo.Transfer(acme, a.ID, b.ID, usd(10), nil); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("Transfer across tenants: got %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete(acme, b.ID, true); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("Delete across tenants: got %v, want ErrNotFound", err)
+	}
+	assertBalance(t, repo, b.ID, 100)
+
+	listed, err := repo.List(acme, repository.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].ID != a.ID {
+		t.Fatalf("List in acme returned %d accounts, want only %s", len(listed), a.ID)
+	}
+
+	// Support staff see and change every tenant's accounts.
+	if listed, err = repo.List(support, repository.ListOptions{}); err != nil || len(listed) != 2 {
+		t.Fatalf("List across tenants returned %d accounts, %v; want 2", len(listed), err)
+	}
+	if _, _, err := repo.Transfer(support, a.ID, b.ID, usd(10), nil); err != nil {
+		t.Fatalf("Transfer by support: %v", err)
+	}
+	if err := repo.Delete(support, b.ID, true); err != nil {
+		t.Fatalf("Delete by support: %v", err)
+	}
+	if _, err := repo.Restore(acme, b.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("Restore across tenants: got %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,69 @@
+package repository
+
+// Status is the lifecycle state of an account. Only active accounts accept
+// changes to their balance.
+type Status string
+
+const (
+	// StatusPending accounts are open but wait to be activated.
+	StatusPending Status = "pending"
+	StatusActive  Status = "active"
+	// StatusFrozen accounts are held, e.g. while under investigation, until
+	// they are made active again.
+	StatusFrozen Status = "frozen"
+	// StatusClosed is final. Accounts are only closed with a zero balance.
+	StatusClosed Status = "closed"
+)
+
+// transitions lists the statuses each status can change to.
+var transitions = map[Status][]Status{
+	StatusPending: {StatusActive},
+	StatusActive:  {StatusFrozen, StatusClosed},
+	StatusFrozen:  {StatusActive},
+}
+
+// CanBecome reports whether an account in status s may change to status to.
+func (s Status) CanBecome(to Status) bool {
+	for _, next := range transitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOpening checks that accounts may be opened in status.
+func checkOpening(status Status) error {
+	if status != StatusPending && status != StatusActive {
+		return ErrStatusTransition.With("to", string(status))
+	}
+	return nil
+}
+
+// checkTransition checks that account may change to status.
+func checkTransition(account Account, status Status) error {
+	if !account.Status.CanBecome(status) {
+		return ErrStatusTransition.With("account_id", account.ID.String()).
+			With("from", string(account.Status)).
+			With("to", string(status))
+	}
+	if status == StatusClosed && account.Balance != 0 {
+		return ErrBalanceNotZero.With("account_id", account.ID.String())
+	}
+	return nil
+}
+
+// checkActive refuses changes to the balance of accounts that are not
+// active.
+func checkActive(account Account) error {
+	switch account.Status {
+	case StatusActive:
+		return nil
+	case StatusFrozen:
+		return ErrFrozen.With("account_id", account.ID.String())
+	case StatusClosed:
+		return ErrClosed.With("account_id", account.ID.String())
+	default:
+		return ErrPending.With("account_id", account.ID.String())
+	}
+}
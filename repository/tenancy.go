@@ -0,0 +1,58 @@
+package repository
+
+import "context"
+
+// Scope limits repository calls to the accounts of one tenant. It travels
+// in the context so that every query of a request is scoped the same way;
+// calls without a Scope, such as those of background workers, see every
+// tenant.
+type Scope struct {
+	// TenantID is the tenant whose accounts are visible, and the tenant
+	// of accounts created in this scope.
+	TenantID string
+	// OwnerID is recorded as the owner of accounts created in this scope.
+	OwnerID string
+	// AllTenants makes the accounts of every tenant visible, for support
+	// staff. New accounts are still created in TenantID.
+	AllTenants bool
+}
+
+type scopeKey struct{}
+
+// WithScope returns a copy of ctx whose repository calls are limited to
+// scope.
+func WithScope(ctx context.Context, scope Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+// ScopeFrom returns the scope of ctx, if it has one.
+func ScopeFrom(ctx context.Context) (Scope, bool) {
+	scope, ok := ctx.Value(scopeKey{}).(Scope)
+	return scope, ok
+}
+
+// Includes reports whether account is visible in the scope.
+func (s Scope) Includes(account Account) bool {
+	return s.AllTenants || s.TenantID == account.TenantID
+}
+
+// visible reports whether account can be seen from ctx.
+func visible(ctx context.Context, account Account) bool {
+	scope, ok := ScopeFrom(ctx)
+	return !ok || scope.Includes(account)
+}
+
+// live reports whether account is visible from ctx and not deleted.
+func live(ctx context.Context, account Account) bool {
+	return account.DeletedAt.IsZero() && visible(ctx, account)
+}
+
+// tenantFilter returns the tenant that queries from ctx are limited to, or
+// false if they see every tenant.
+func tenantFilter(ctx context.Context) (string, bool) {
+	scope, ok := ScopeFrom(ctx)
+	if !ok || scope.AllTenants {
+		return "", false
+	}
+	return scope.TenantID, true
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/auth"
+	"github.com/yaninyzwitty/golang-proj-with-db/config"
+	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+// authPolicy is the scope each RPC requires. Probes and reflection need no
+// token.
+var authPolicy = auth.Policy{
+	Scopes: map[string]string{
+		pb.CommerceTransactions_CreateTransaction_FullMethodName:    auth.ScopeWrite,
+		pb.CommerceTransactions_UpdateTransaction_FullMethodName:    auth.ScopeWrite,
+		pb.CommerceTransactions_TransferFunds_FullMethodName:        auth.ScopeWrite,
+		pb.CommerceTransactions_DeleteTransaction_FullMethodName:    auth.ScopeDelete,
+		pb.CommerceTransactions_RestoreTransaction_FullMethodName:   auth.ScopeDelete,
+		pb.CommerceTransactions_SetTransactionStatus_FullMethodName: auth.ScopeStatus,
+		pb.CommerceTransactions_GetTransaction_FullMethodName:       auth.ScopeRead,
+		pb.CommerceTransactions_ListTransactions_FullMethodName:     auth.ScopeRead,
+		pb.CommerceTransactions_WatchTransactions_FullMethodName:    auth.ScopeRead,
+	},
+	Public: map[string]bool{
+		healthpb.Health_Check_FullMethodName:                                   true,
+		healthpb.Health_Watch_FullMethodName:                                   true,
+		reflectionv1.ServerReflection_ServerReflectionInfo_FullMethodName:      true,
+		reflectionv1alpha.ServerReflection_ServerReflectionInfo_FullMethodName: true,
+	},
+}
+
+// newAuthInterceptor fails unless AUTH_HS256_SECRET or AUTH_JWKS_FILE is
+// set, so a missing setting never leaves the server open. It returns nil
+// only when AUTH_DISABLED explicitly turns authentication off.
+func newAuthInterceptor(cfg *config.Config) (*auth.Interceptor, error) {
+	configured := cfg.AUTH_HS256_SECRET != "" || cfg.AUTH_JWKS_FILE != ""
+	switch {
+	case cfg.AUTH_DISABLED && configured:
+		return nil, errors.New("AUTH_DISABLED is set along with AUTH_HS256_SECRET or AUTH_JWKS_FILE")
+	case cfg.AUTH_DISABLED:
+		return nil, nil
+	case !configured:
+		return nil, errors.New("set AUTH_HS256_SECRET or AUTH_JWKS_FILE, or AUTH_DISABLED=true to run without authentication")
+	}
+	var err error
+	var keys map[string]*rsa.PublicKey
+	if cfg.AUTH_JWKS_FILE != "" {
+		if keys, err = auth.LoadJWKS(cfg.AUTH_JWKS_FILE); err != nil {
+			return nil, err
+		}
+	}
+	verifier, err := auth.NewVerifier([]byte(cfg.AUTH_HS256_SECRET), keys, cfg.AUTH_ISSUER, cfg.AUTH_AUDIENCE)
+	if err != nil {
+		return nil, err
+	}
+	interceptor := auth.NewInterceptor(verifier, authPolicy)
+	interceptor.OnAuthenticated = tenantScope
+	return interceptor, nil
+}
+
+// tenantScope limits the repository calls of an RPC to the caller's
+// tenant, or to no tenant in particular for admins.
+func tenantScope(ctx context.Context, principal *auth.Principal) (context.Context, error) {
+	admin := principal.HasRole(auth.RoleAdmin)
+	if principal.TenantID == "" && !admin {
+		return nil, status.Errorf(codes.PermissionDenied, "token has no tenant_id")
+	}
+	return repository.WithScope(ctx, repository.Scope{
+		TenantID:   principal.TenantID,
+		OwnerID:    principal.Subject,
+		AllTenants: admin,
+	}), nil
+}
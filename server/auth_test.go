@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/auth"
+	"github.com/yaninyzwitty/golang-proj-with-db/config"
+	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthPolicyCoversEveryRPC(t *testing.T) {
+	desc := pb.CommerceTransactions_ServiceDesc
+	var methods []string
+	for _, method := range desc.Methods {
+		methods = append(methods, "/"+desc.ServiceName+"/"+method.MethodName)
+	}
+	for _, stream := range desc.Streams {
+		methods = append(methods, "/"+desc.ServiceName+"/"+stream.StreamName)
+	}
+	for _, method := range methods {
+		if _, ok := authPolicy.Scopes[method]; !ok {
+			t.Errorf("%s has no scope in authPolicy", method)
+		}
+	}
+}
+
+func TestTenantScope(t *testing.T) {
+	ctx := context.Background()
+	user := &auth.Principal{Subject: "alice", TenantID: "acme"}
+	scoped, err := tenantScope(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope, _ := repository.ScopeFrom(scoped)
+	if scope != (repository.Scope{TenantID: "acme", OwnerID: "alice"}) {
+		t.Fatalf("scope = %+v", scope)
+	}
+
+	admin := &auth.Principal{Subject: "root", Roles: map[string]bool{auth.RoleAdmin: true}}
+	scoped, err = tenantScope(ctx, admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scope, _ := repository.ScopeFrom(scoped); !scope.AllTenants {
+		t.Fatalf("admin scope = %+v, want all tenants", scope)
+	}
+
+	if _, err := tenantScope(ctx, &auth.Principal{Subject: "mallory"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("principal without tenant: got %v, want PermissionDenied", err)
+	}
+}
+
+func TestNewAuthInterceptorFailsClosed(t *testing.T) {
+	if _, err := newAuthInterceptor(&config.Config{}); err == nil {
+		t.Error("no auth config: want an error")
+	}
+	if interceptor, err := newAuthInterceptor(&config.Config{AUTH_DISABLED: true}); interceptor != nil || err != nil {
+		t.Errorf("AUTH_DISABLED = %v, %v, want nil, nil", interceptor, err)
+	}
+	if _, err := newAuthInterceptor(&config.Config{AUTH_DISABLED: true, AUTH_HS256_SECRET: "secret"}); err == nil {
+		t.Error("AUTH_DISABLED with a secret: want an error")
+	}
+	if interceptor, err := newAuthInterceptor(&config.Config{AUTH_HS256_SECRET: "secret"}); interceptor == nil || err != nil {
+		t.Errorf("secret = %v, %v, want an interceptor", interceptor, err)
+	}
+}
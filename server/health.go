@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yaninyzwitty/golang-proj-with-db/database"
+	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// healthReporter publishes the server's status through the standard gRPC
+// health service, both overall ("") and for CommerceTransactions. The
+// server is SERVING once migrations have finished and for as long as the
+// database answers pings.
+type healthReporter struct {
+	server *health.Server
+
+	mu        sync.Mutex
+	migrating bool
+	dbErr     error
+}
+
+// newHealthReporter starts out NOT_SERVING, as if migrations were running.
+func newHealthReporter() *healthReporter {
+	h := &healthReporter{server: health.NewServer(), migrating: true}
+	h.update()
+	return h
+}
+
+func (h *healthReporter) setMigrating(migrating bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.migrating = migrating
+	h.update()
+}
+
+func (h *healthReporter) setDatabaseError(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if (err == nil) != (h.dbErr == nil) {
+		if err != nil {
+			slog.Error("database is unreachable, reporting NOT_SERVING", "error", err)
+		} else {
+			slog.Info("database is reachable again")
+		}
+	}
+	h.dbErr = err
+	h.update()
+}
+
+func (h *healthReporter) isMigrating() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.migrating
+}
+
+// errMigrating is returned for RPCs made while migrations run.
+var errMigrating = status.Error(codes.Unavailable, "server is applying migrations, retry shortly")
+
+// UnaryServerInterceptor rejects RPCs with Unavailable until migrations
+// have finished, so clients that ignore the health service never run
+// against the old schema. Methods in open, such as the health checks,
+// are always served.
+func (h *healthReporter) UnaryServerInterceptor(open map[string]bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !open[info.FullMethod] && h.isMigrating() {
+			return nil, errMigrating
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of
+// UnaryServerInterceptor.
+func (h *healthReporter) StreamServerInterceptor(open map[string]bool) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !open[info.FullMethod] && h.isMigrating() {
+			return errMigrating
+		}
+		return handler(srv, ss)
+	}
+}
+
+// update must be called with h.mu held.
+func (h *healthReporter) update() {
+	status := healthpb.HealthCheckResponse_SERVING
+	if h.migrating || h.dbErr != nil {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	h.server.SetServingStatus("", status)
+	h.server.SetServingStatus(pb.CommerceTransactions_ServiceDesc.ServiceName, status)
+}
+
+// pingDatabase reports whether the database answers, immediately and then
+// every interval until ctx is cancelled.
+func (h *healthReporter) pingDatabase(ctx context.Context, pool *pgxpool.Pool, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := database.Ping(ctx, pool, timeout)
+		if ctx.Err() != nil {
+			return
+		}
+		h.setDatabaseError(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestHealthReporter(t *testing.T) {
+	h := newHealthReporter()
+	check := func(want healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		for _, service := range []string{"", pb.CommerceTransactions_ServiceDesc.ServiceName} {
+			res, err := h.server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Status != want {
+				t.Fatalf("service %q is %v, want %v", service, res.Status, want)
+			}
+		}
+	}
+
+	check(healthpb.HealthCheckResponse_NOT_SERVING)
+	h.setMigrating(false)
+	check(healthpb.HealthCheckResponse_SERVING)
+	h.setDatabaseError(errors.New("connection refused"))
+	check(healthpb.HealthCheckResponse_NOT_SERVING)
+	h.setDatabaseError(nil)
+	check(healthpb.HealthCheckResponse_SERVING)
+}
+
+func TestHealthReporterGatesRPCsWhileMigrating(t *testing.T) {
+	h := newHealthReporter()
+	interceptor := h.UnaryServerInterceptor(authPolicy.Public)
+	call := func(method string) error {
+		_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req any) (any, error) { return nil, nil })
+		return err
+	}
+
+	if err := call(pb.CommerceTransactions_GetTransaction_FullMethodName); status.Code(err) != codes.Unavailable {
+		t.Errorf("RPC while migrating: %v, want Unavailable", err)
+	}
+	if err := call(healthpb.Health_Check_FullMethodName); err != nil {
+		t.Errorf("health check while migrating: %v", err)
+	}
+	h.setMigrating(false)
+	if err := call(pb.CommerceTransactions_GetTransaction_FullMethodName); err != nil {
+		t.Errorf("RPC after migrating: %v", err)
+	}
+}
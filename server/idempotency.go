@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/domain"
+	"github.com/yaninyzwitty/golang-proj-with-db/idempotency"
+	"github.com/yaninyzwitty/golang-proj-with-db/logging"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// idempotencyKeyHeader is the metadata alternative to the idempotency_key
+// request field.
+const idempotencyKeyHeader = "idempotency-key"
+
+// idempotent runs handle at most once per idempotency key, except within
+// the reservation lease described in package idempotency. A retry with the
+// same key and an identical request gets the stored response back, while a
+// different request under the same key fails with AlreadyExists. Requests
+// without a key run handle directly.
+func idempotent[Res proto.Message](ctx context.Context, s *GrpcServer, method, key string, req proto.Message, handle func() (Res, error)) (Res, error) {
+	var zero Res
+	if key == "" {
+		key = idempotencyKeyFromMetadata(ctx)
+	}
+	if key == "" {
+		return handle()
+	}
+	// Scope keys per method so the same key on different RPCs never
+	// collides, and per tenant so one tenant can never be handed another's
+	// response.
+	if scope, ok := repository.ScopeFrom(ctx); ok {
+		key = scope.TenantID + ":" + key
+	}
+	key = method + ":" + key
+
+	hash, err := requestHash(req)
+	if err != nil {
+		return zero, fmt.Errorf("hashing request: %w", err)
+	}
+
+	record, reserved, err := s.idempotency.Reserve(ctx, key, hash, s.idempotencyLease)
+	if err != nil {
+		return zero, fmt.Errorf("reserving idempotency key: %w", err)
+	}
+	if !reserved {
+		if string(record.RequestHash) != string(hash) {
+			return zero, domain.ErrIdempotencyKeyReuse
+		}
+		if record.Pending() {
+			return zero, domain.ErrIdempotencyPending
+		}
+		res := zero.ProtoReflect().New().Interface().(Res)
+		if err := proto.Unmarshal(record.Response, res); err != nil {
+			return zero, fmt.Errorf("decoding stored response: %w", err)
+		}
+		return res, nil
+	}
+
+	res, err := handle()
+	if err != nil {
+		// Let the client retry a failed request under the same key.
+		if releaseErr := s.idempotency.Release(context.WithoutCancel(ctx), key, record.Token); releaseErr != nil {
+			logging.FromContext(ctx).Error("failed to release idempotency key", "error", releaseErr)
+		}
+		return zero, err
+	}
+
+	response, err := proto.MarshalOptions{Deterministic: true}.Marshal(res)
+	if err == nil {
+		err = s.idempotency.Complete(context.WithoutCancel(ctx), key, record.Token, response, s.idempotencyTTL)
+	}
+	if err != nil {
+		// The mutation has already committed, so report success; a retry
+		// will see the pending reservation until its lease expires.
+		logging.FromContext(ctx).Error("failed to store idempotent response", "error", err)
+	}
+	return res, nil
+}
+
+func idempotencyKeyFromMetadata(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(idempotencyKeyHeader); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// requestHash fingerprints req without its idempotency_key field, so a key
+// sent in metadata and the same key sent in the body are equivalent.
+func requestHash(req proto.Message) ([]byte, error) {
+	clone := proto.Clone(req)
+	m := clone.ProtoReflect()
+	if fd := m.Descriptor().Fields().ByName("idempotency_key"); fd != nil {
+		m.Clear(fd)
+	}
+	raw, err := proto.MarshalOptions{Deterministic: true}.Marshal(clone)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(raw)
+	return sum[:], nil
+}
+
+// purgeIdempotencyKeys periodically removes expired idempotency records.
+func purgeIdempotencyKeys(ctx context.Context, store idempotency.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := store.Purge(ctx)
+			if err != nil {
+				slog.Error("failed to purge idempotency keys", "error", err)
+				continue
+			}
+			if purged > 0 {
+				slog.Info("purged expired idempotency keys", "count", purged)
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/domain"
+	"github.com/yaninyzwitty/golang-proj-with-db/idempotency"
+	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const createMethod = pb.CommerceTransactions_CreateTransaction_FullMethodName
+
+func newIdempotentServer() *GrpcServer {
+	return &GrpcServer{
+		idempotency:      idempotency.NewMemoryStore(),
+		idempotencyTTL:   time.Hour,
+		idempotencyLease: time.Minute,
+	}
+}
+
+// countingHandler returns a handler that counts its calls and responds
+// with the call number as the transaction ID.
+func countingHandler(calls *int) func() (*pb.TransactionResponse, error) {
+	return func() (*pb.TransactionResponse, error) {
+		*calls++
+		return &pb.TransactionResponse{Success: true, TransactionId: strconv.Itoa(*calls)}, nil
+	}
+}
+
+func createRequest(amount int64, key string) *pb.CreateTransactionRequest {
+	return &pb.CreateTransactionRequest{Balance: &pb.Money{Currency: "USD", Amount: amount}, IdempotencyKey: key}
+}
+
+func TestIdempotentReplaysResponse(t *testing.T) {
+	s := newIdempotentServer()
+	ctx := context.Background()
+	calls := 0
+	req := createRequest(100, "key-1")
+
+	first, err := idempotent(ctx, s, createMethod, req.IdempotencyKey, req, countingHandler(&calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := idempotent(ctx, s, createMethod, req.IdempotencyKey, req, countingHandler(&calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+	if !proto.Equal(first, second) {
+		t.Errorf("replayed response = %v, want %v", second, first)
+	}
+
+	// Without a key every call runs.
+	for i := 0; i < 2; i++ {
+		if _, err := idempotent(ctx, s, createMethod, "", createRequest(100, ""), countingHandler(&calls)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("handler ran %d times, want 3", calls)
+	}
+}
+
+func TestIdempotentRejectsDifferentRequest(t *testing.T) {
+	s := newIdempotentServer()
+	ctx := context.Background()
+	calls := 0
+
+	if _, err := idempotent(ctx, s, createMethod, "key-1", createRequest(100, "key-1"), countingHandler(&calls)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := idempotent(ctx, s, createMethod, "key-1", createRequest(200, "key-1"), countingHandler(&calls))
+	if !errors.Is(err, domain.ErrIdempotencyKeyReuse) {
+		t.Fatalf("different request error = %v, want ErrIdempotencyKeyReuse", err)
+	}
+	if code := domain.Status(slog.Default(), err).Code(); code != codes.AlreadyExists {
+		t.Errorf("different request code = %v, want AlreadyExists", code)
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+
+	// The same key on another method is a different key.
+	if _, err := idempotent(ctx, s, pb.CommerceTransactions_TransferFunds_FullMethodName, "key-1", createRequest(200, "key-1"), countingHandler(&calls)); err != nil {
+		t.Errorf("same key on another method: %v", err)
+	}
+}
+
+func TestIdempotentKeyFromMetadata(t *testing.T) {
+	s := newIdempotentServer()
+	calls := 0
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(idempotencyKeyHeader, "key-1"))
+
+	first, err := idempotent(ctx, s, createMethod, "", createRequest(100, ""), countingHandler(&calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The key in the body is the same key as in metadata.
+	second, err := idempotent(context.Background(), s, createMethod, "key-1", createRequest(100, "key-1"), countingHandler(&calls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || !proto.Equal(first, second) {
+		t.Errorf("handler ran %d times with responses %v and %v, want one run", calls, first, second)
+	}
+}
+
+func TestIdempotentReleasesFailedKey(t *testing.T) {
+	s := newIdempotentServer()
+	ctx := context.Background()
+	failure := errors.New("database unavailable")
+
+	_, err := idempotent(ctx, s, createMethod, "key-1", createRequest(100, "key-1"), func() (*pb.TransactionResponse, error) {
+		return nil, failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("failed handler error = %v, want %v", err, failure)
+	}
+
+	calls := 0
+	if _, err := idempotent(ctx, s, createMethod, "key-1", createRequest(100, "key-1"), countingHandler(&calls)); err != nil {
+		t.Fatalf("retry after failure: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("retry ran the handler %d times, want 1", calls)
+	}
+}
+
+func TestIdempotentPendingKey(t *testing.T) {
+	s := newIdempotentServer()
+	ctx := context.Background()
+	calls := 0
+
+	_, err := idempotent(ctx, s, createMethod, "key-1", createRequest(100, "key-1"), func() (*pb.TransactionResponse, error) {
+		// A retry that arrives while the original is still running.
+		_, err := idempotent(ctx, s, createMethod, "key-1", createRequest(100, "key-1"), countingHandler(&calls))
+		if !errors.Is(err, domain.ErrIdempotencyPending) {
+			t.Errorf("concurrent retry error = %v, want ErrIdempotencyPending", err)
+		}
+		return &pb.TransactionResponse{Success: true}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 {
+		t.Errorf("concurrent retry ran the handler %d times", calls)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/domain"
+	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+)
+
+var accountStatuses = map[pb.TransactionStatus]repository.Status{
+	pb.TransactionStatus_TRANSACTION_STATUS_PENDING: repository.StatusPending,
+	pb.TransactionStatus_TRANSACTION_STATUS_ACTIVE:  repository.StatusActive,
+	pb.TransactionStatus_TRANSACTION_STATUS_FROZEN:  repository.StatusFrozen,
+	pb.TransactionStatus_TRANSACTION_STATUS_CLOSED:  repository.StatusClosed,
+}
+
+// statusFromProto converts a requested status, rejecting unspecified and
+// unknown values with ErrInvalidStatus.
+func statusFromProto(s pb.TransactionStatus) (repository.Status, error) {
+	accountStatus, ok := accountStatuses[s]
+	if !ok {
+		return "", fmt.Errorf("%w %v", domain.ErrInvalidStatus.With("field", "status"), s)
+	}
+	return accountStatus, nil
+}
+
+func statusToProto(s repository.Status) pb.TransactionStatus {
+	for protoStatus, accountStatus := range accountStatuses {
+		if accountStatus == s {
+			return protoStatus
+		}
+	}
+	return pb.TransactionStatus_TRANSACTION_STATUS_UNSPECIFIED
+}
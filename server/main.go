@@ -2,19 +2,40 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
 	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
-	crdbpgx "github.com/cockroachdb/cockroach-go/v2/crdb/crdbpgxv5"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/yaninyzwitty/golang-proj-with-db/config"
+	"github.com/yaninyzwitty/golang-proj-with-db/database"
+	"github.com/yaninyzwitty/golang-proj-with-db/domain"
+	"github.com/yaninyzwitty/golang-proj-with-db/idempotency"
+	"github.com/yaninyzwitty/golang-proj-with-db/logging"
+	"github.com/yaninyzwitty/golang-proj-with-db/metrics"
+	"github.com/yaninyzwitty/golang-proj-with-db/money"
+	"github.com/yaninyzwitty/golang-proj-with-db/outbox"
 	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+	"github.com/yaninyzwitty/golang-proj-with-db/tlsconfig"
+	"github.com/yaninyzwitty/golang-proj-with-db/tracing"
+	"github.com/yaninyzwitty/golang-proj-with-db/validation"
+	"github.com/yaninyzwitty/golang-proj-with-db/watch"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 var (
@@ -23,109 +44,308 @@ var (
 
 type GrpcServer struct {
 	pb.UnimplementedCommerceTransactionsServer
-	db *pgx.Conn
+	repo repository.AccountRepository
+
+	idempotency      idempotency.Store
+	idempotencyTTL   time.Duration
+	idempotencyLease time.Duration
+
+	watch watch.Source
+
+	metrics *metrics.Metrics
+	// shutdown is closed when the server starts shutting down, to end
+	// streams that would otherwise hold up GracefulStop.
+	shutdown <-chan struct{}
 }
 
 func main() {
+	flag.Parse()
+	if flag.NArg() > 0 && flag.Arg(0) != "migrate" {
+		slog.Error("unknown command", "command", flag.Arg(0))
+		os.Exit(2)
+	}
+
+	// Cancelled on SIGINT or SIGTERM, which starts a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Load the configuration files
 	cfg := config.NewConfig()
+	logger, err := logging.New(os.Stderr, cfg.LOG_LEVEL, cfg.LOG_FORMAT)
+	if err != nil {
+		slog.Error("error configuring logging", "error", err)
+		return
+	}
+	slog.SetDefault(logger)
 
-	// Parse database configuration
-	pgxConfig, err := pgx.ParseConfig(cfg.DATABASE_URL)
+	// Export traces of RPCs and queries. Set up before the pool so its
+	// tracer finds the provider.
+	exporter, err := newSpanExporter(ctx, cfg)
 	if err != nil {
-		slog.Error("error parsing connection configuration", "error", err)
+		slog.Error("error configuring tracing", "error", err)
 		return
 	}
+	if exporter != nil {
+		shutdownTracing := tracing.Setup(exporter, serviceName)
+		defer func() {
+			// Flush the remaining spans; ctx is already cancelled by now.
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				slog.Error("failed to flush traces", "error", err)
+			}
+		}()
+	}
 
-	pgxConfig.RuntimeParams["application_name"] = "docs_simplecrud_gopgx" //for debugging, not really necessary
-	conn, err := pgx.ConnectConfig(context.Background(), pgxConfig)
+	// Connect to the database through a pool shared by all handlers. It is
+	// closed last, once nothing can use it anymore.
+	pool, err := database.NewPool(ctx, cfg)
 	if err != nil {
 		slog.Error("Error connecting to database", "error", err)
 		return
 	}
-	defer conn.Close(context.Background())
+	defer pool.Close()
 
-	// Set up table
-	err = crdbpgx.ExecuteTx(context.Background(), conn, pgx.TxOptions{}, func(tx pgx.Tx) error {
-		return initTable(context.Background(), tx)
-	})
-	if err != nil {
-		slog.Error("error initializing table", "error", err)
+	if flag.NArg() > 0 {
+		err := runMigrate(ctx, pool, flag.Args()[1:])
+		pool.Close()
+		if err != nil {
+			slog.Error("migration failed", "error", err)
+			os.Exit(1)
+		}
 		return
 	}
 
 	// Set up gRPC server
-	flag.Parse()
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
 	if err != nil {
 		slog.Error("failed to listen", "error", err)
 		return
 	}
 
-	server := grpc.NewServer()
-	grpcServer := &GrpcServer{db: conn}
+	// Deliver the events that repository changes write to the outbox
+	outboxEvents := make(chan outbox.Event)
+	sink, err := newOutboxSink(cfg, outboxEvents)
+	if err != nil {
+		slog.Error("error configuring outbox sink", "error", err)
+		return
+	}
+	defer sink.Close()
+
+	// Background workers run until the server has stopped, and are waited
+	// for before the sink and pool they use are closed.
+	workers, stopWorkers := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer stopWorkers()
+	runWorker := func(work func(context.Context)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(workers)
+		}()
+	}
+
+	if cfg.OUTBOX_SINK == "channel" {
+		runWorker(func(ctx context.Context) {
+			logOutboxEvents(ctx, outboxEvents)
+		})
+	}
+	relay := outbox.NewRelay(outbox.NewPgxStore(pool), sink, cfg.OUTBOX_POLL_INTERVAL)
+	relay.BatchSize = cfg.OUTBOX_BATCH_SIZE
+	relay.MaxBackoff = cfg.OUTBOX_MAX_BACKOFF
+
+	serverMetrics := metrics.New()
+	serverMetrics.Registry.MustRegister(metrics.NewPoolCollector(pool))
+	serverMetrics.RegisterOutboxLag(relay.Lag)
+	if cfg.METRICS_ADDR != "" {
+		metricsLis, err := net.Listen("tcp", cfg.METRICS_ADDR)
+		if err != nil {
+			slog.Error("failed to listen for metrics", "error", err)
+			return
+		}
+		runWorker(func(ctx context.Context) {
+			serveMetrics(ctx, metricsLis, serverMetrics.Handler())
+		})
+	}
+
+	idempotencyStore := idempotency.NewPgxStore(pool)
+	repo := repository.NewPgxRepository(pool)
+	watchSource, err := newWatchSource(cfg, pool, repo)
+	if err != nil {
+		slog.Error("error configuring watch source", "error", err)
+		return
+	}
+
+	var serverOptions []grpc.ServerOption
+	if cfg.TLS_CERT_FILE != "" {
+		reloader, err := tlsconfig.NewReloader(cfg.TLS_CERT_FILE, cfg.TLS_KEY_FILE, cfg.TLS_CA_FILE)
+		if err != nil {
+			slog.Error("error loading TLS certificates", "error", err)
+			return
+		}
+		tlsConfig, err := reloader.ServerConfig(cfg.TLS_REQUIRE_CLIENT_CERT)
+		if err != nil {
+			slog.Error("error configuring TLS", "error", err)
+			return
+		}
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		runWorker(func(ctx context.Context) {
+			reloader.Run(ctx, cfg.TLS_RELOAD_INTERVAL)
+		})
+	}
+
+	// Trace every RPC, continuing the caller's trace, and log it under the
+	// same trace ID, including those rejected by authentication.
+	serverOptions = append(serverOptions,
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(logging.UnaryServerInterceptor(logger)),
+		grpc.ChainStreamInterceptor(logging.StreamServerInterceptor(logger)))
+	serverOptions = append(serverOptions,
+		grpc.ChainUnaryInterceptor(serverMetrics.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(serverMetrics.StreamServerInterceptor()))
+	// Only probes and reflection are served until the schema is up to
+	// date.
+	health := newHealthReporter()
+	serverOptions = append(serverOptions,
+		grpc.ChainUnaryInterceptor(health.UnaryServerInterceptor(authPolicy.Public)),
+		grpc.ChainStreamInterceptor(health.StreamServerInterceptor(authPolicy.Public)))
+
+	authInterceptor, err := newAuthInterceptor(cfg)
+	if err != nil {
+		slog.Error("error configuring authentication", "error", err)
+		return
+	}
+	if authInterceptor != nil {
+		serverOptions = append(serverOptions,
+			grpc.ChainUnaryInterceptor(authInterceptor.Unary()),
+			grpc.ChainStreamInterceptor(authInterceptor.Stream()))
+	} else {
+		slog.Warn("authentication is disabled by AUTH_DISABLED, every caller can reach every tenant's accounts")
+	}
+	// Reject requests that break the rules declared in the protos, once
+	// the caller is known to be allowed to make them. Handler errors are
+	// converted to statuses last, so internal details are logged rather
+	// than sent.
+	serverOptions = append(serverOptions,
+		grpc.ChainUnaryInterceptor(validation.UnaryServerInterceptor(), domain.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(validation.StreamServerInterceptor(), domain.StreamServerInterceptor()))
+
+	server := grpc.NewServer(serverOptions...)
+	healthpb.RegisterHealthServer(server, health.server)
+	grpcServer := &GrpcServer{
+		repo:             repo,
+		idempotency:      idempotencyStore,
+		idempotencyTTL:   cfg.IDEMPOTENCY_TTL,
+		idempotencyLease: cfg.IDEMPOTENCY_LEASE,
+		watch:            watchSource,
+		metrics:          serverMetrics,
+		shutdown:         ctx.Done(),
+	}
 	pb.RegisterCommerceTransactionsServer(server, grpcServer)
-	slog.Info("server listening", "address", lis.Addr().String())
-	if err := server.Serve(lis); err != nil {
-		slog.Error("failed to serve", "error", err)
+	if cfg.ENABLE_REFLECTION {
+		if err := registerReflection(server); err != nil {
+			slog.Error("error registering reflection", "error", err)
+			return
+		}
 	}
-}
 
-func initTable(ctx context.Context, tx pgx.Tx) error {
-	// Drop existing table if it exists
-	slog.Info("Dropping existing accounts table if necessary.")
-	if _, err := tx.Exec(ctx, "DROP TABLE IF EXISTS accounts"); err != nil {
-		return err
+	// Serve before migrating, so health checks can see NOT_SERVING while
+	// the schema is brought up to date; other RPCs are refused until then
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("server listening", "address", lis.Addr().String())
+		serveErr <- server.Serve(lis)
+	}()
+
+	// Bring the schema up to date
+	if cfg.MIGRATE_ON_START {
+		if err := migrateUp(ctx, pool); err != nil {
+			slog.Error("error applying migrations", "error", err)
+			server.Stop()
+			return
+		}
 	}
+	health.setMigrating(false)
+
+	runWorker(func(ctx context.Context) {
+		health.pingDatabase(ctx, pool, cfg.HEALTH_CHECK_INTERVAL, cfg.HEALTH_CHECK_TIMEOUT)
+	})
+	runWorker(func(ctx context.Context) {
+		purgeIdempotencyKeys(ctx, idempotencyStore, cfg.IDEMPOTENCY_PURGE_INTERVAL)
+	})
+	runWorker(func(ctx context.Context) {
+		purgeDeletedAccounts(ctx, repo, cfg.DELETED_RETENTION, cfg.DELETED_PURGE_INTERVAL)
+	})
+	runWorker(relay.Run)
 
-	// Create the accounts table
-	slog.Info("Creating accounts table.")
-	if _, err := tx.Exec(ctx,
-		"CREATE TABLE accounts (id UUID PRIMARY KEY DEFAULT gen_random_uuid(), balance INT8)"); err != nil {
-		return err
+	select {
+	case err := <-serveErr:
+		if err != nil {
+			slog.Error("failed to serve", "error", err)
+		}
+	case <-ctx.Done():
+		// A second signal kills the process without waiting
+		stop()
+		slog.Info("shutting down", "timeout", cfg.SHUTDOWN_TIMEOUT)
+		// Tell load balancers to stop routing here while RPCs drain
+		health.server.Shutdown()
+		gracefulStop(server, cfg.SHUTDOWN_TIMEOUT)
+		slog.Info("server stopped")
 	}
-	return nil
 }
 
 func (s *GrpcServer) CreateTransaction(ctx context.Context, req *pb.CreateTransactionRequest) (*pb.TransactionResponse, error) {
-	newUUID := uuid.New()
+	return idempotent(ctx, s, pb.CommerceTransactions_CreateTransaction_FullMethodName, req.IdempotencyKey, req, func() (*pb.TransactionResponse, error) {
+		balance, err := moneyFromProto("balance", req.Balance)
+		if err != nil {
+			return nil, err
+		}
 
-	_, err := s.db.Exec(ctx, "INSERT INTO accounts (id, balance) VALUES ($1, $2)", newUUID, req.Balance)
-	if err != nil {
-		slog.Error("failed to create transaction", "error", err)
-		return nil, status.Errorf(codes.Internal, "failed to create transaction: %v", err)
-	}
+		opening := repository.StatusActive
+		if req.Pending {
+			opening = repository.StatusPending
+		}
+		account, err := s.repo.Create(ctx, balance, opening)
+		if err != nil {
+			return nil, fmt.Errorf("creating transaction: %w", err)
+		}
 
-	return &pb.TransactionResponse{
-		Success:       true,
-		Message:       "Transaction created successfully",
-		Balance:       req.Balance,
-		TransactionId: newUUID.String(),
-	}, nil
+		return &pb.TransactionResponse{
+			Success:       true,
+			Message:       "Transaction created successfully",
+			Balance:       moneyToProto(account.Money()),
+			Version:       account.Version,
+			TransactionId: account.ID.String(),
+			Status:        statusToProto(account.Status),
+		}, nil
+	})
 }
 
 func (s *GrpcServer) UpdateTransaction(ctx context.Context, req *pb.UpdateTransactionRequest) (*pb.TransactionResponse, error) {
 	transactionId, err := uuid.Parse(req.TransactionId)
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "invalid transaction ID: %v", err)
+		return nil, invalidID("transaction_id", err)
 	}
 
-	// Update the transaction in the database
-
-	result, err := s.db.Exec(ctx, "UPDATE accounts SET balance = $1 WHERE id = $2", req.Balance, transactionId)
+	balance, err := moneyFromProto("balance", req.Balance)
 	if err != nil {
-		slog.Error("failed to update transaction", "error", err)
-		return nil, status.Errorf(codes.Internal, "failed to update transaction: %v", err)
+		return nil, err
 	}
-	if result.RowsAffected() == 0 {
-		return nil, status.Errorf(codes.NotFound, "Transaction not found")
+
+	// Update the transaction in the database
+	account, err := s.repo.UpdateBalance(ctx, transactionId, balance, req.ExpectedVersion)
+	if err != nil {
+		return nil, fmt.Errorf("updating transaction: %w", err)
 	}
 
 	return &pb.TransactionResponse{
 		Success:       true,
 		Message:       "Transaction updated successfully",
-		Balance:       req.Balance,
+		Balance:       moneyToProto(account.Money()),
+		Version:       account.Version,
 		TransactionId: req.TransactionId,
+		Status:        statusToProto(account.Status),
 	}, nil
 }
 
@@ -133,19 +353,11 @@ func (s *GrpcServer) DeleteTransaction(ctx context.Context, req *pb.DeleteTransa
 	// parsed the trasaction id
 	transactionId, err := uuid.Parse(req.TransactionId)
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "invalid transaction ID: %v", err)
+		return nil, invalidID("transaction_id", err)
 	}
 
-	result, err := s.db.Exec(ctx, "DELETE FROM accounts WHERE id = $1", transactionId)
-	if err != nil {
-		slog.Error("failed to delete transaction", "error", err)
-		return nil, status.Errorf(codes.Internal, "failed to delete transaction: %v", err)
-	}
-	if result.RowsAffected() == 0 {
-		return &pb.DeleteTransactionResponse{
-			Success: false,
-			Message: "Transaction not found",
-		}, nil
+	if err := s.repo.Delete(ctx, transactionId, req.Force); err != nil {
+		return nil, fmt.Errorf("deleting transaction: %w", err)
 	}
 
 	return &pb.DeleteTransactionResponse{
@@ -154,29 +366,307 @@ func (s *GrpcServer) DeleteTransaction(ctx context.Context, req *pb.DeleteTransa
 	}, nil
 }
 
+func (s *GrpcServer) RestoreTransaction(ctx context.Context, req *pb.RestoreTransactionRequest) (*pb.TransactionResponse, error) {
+	transactionId, err := uuid.Parse(req.TransactionId)
+	if err != nil {
+		return nil, invalidID("transaction_id", err)
+	}
+
+	account, err := s.repo.Restore(ctx, transactionId)
+	if err != nil {
+		return nil, fmt.Errorf("restoring transaction: %w", err)
+	}
+
+	return &pb.TransactionResponse{
+		Success:       true,
+		Message:       "Transaction restored successfully",
+		Balance:       moneyToProto(account.Money()),
+		Version:       account.Version,
+		TransactionId: req.TransactionId,
+		Status:        statusToProto(account.Status),
+	}, nil
+}
+
+func (s *GrpcServer) SetTransactionStatus(ctx context.Context, req *pb.SetTransactionStatusRequest) (*pb.TransactionResponse, error) {
+	transactionId, err := uuid.Parse(req.TransactionId)
+	if err != nil {
+		return nil, invalidID("transaction_id", err)
+	}
+	accountStatus, err := statusFromProto(req.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := s.repo.SetStatus(ctx, transactionId, accountStatus, req.Reason)
+	if err != nil {
+		return nil, fmt.Errorf("setting transaction status: %w", err)
+	}
+	logging.FromContext(ctx).Info("transaction status changed", "transaction_id", req.TransactionId, "status", account.Status, "reason", req.Reason)
+
+	return &pb.TransactionResponse{
+		Success:       true,
+		Message:       "Transaction status changed successfully",
+		Balance:       moneyToProto(account.Money()),
+		Version:       account.Version,
+		TransactionId: req.TransactionId,
+		Status:        statusToProto(account.Status),
+	}, nil
+}
+
 func (s *GrpcServer) GetTransaction(ctx context.Context, req *pb.GetTransactionRequest) (*pb.GetTransactionResponse, error) {
 	// Parse the transaction ID as a UUID
 	transactionId, err := uuid.Parse(req.TransactionId)
 	if err != nil {
-		return nil, status.Errorf(codes.InvalidArgument, "invalid transaction ID: %v", err)
+		return nil, invalidID("transaction_id", err)
 	}
 
 	// Query the transaction from the database
-	row := s.db.QueryRow(ctx, "SELECT balance FROM accounts WHERE id = $1", transactionId)
+	account, err := s.repo.Get(ctx, transactionId)
+	if err != nil {
+		return nil, fmt.Errorf("getting transaction: %w", err)
+	}
+
+	return &pb.GetTransactionResponse{
+		Balance:       moneyToProto(account.Money()),
+		Version:       account.Version,
+		TransactionId: req.TransactionId,
+		TenantId:      account.TenantID,
+		OwnerId:       account.OwnerID,
+		Status:        statusToProto(account.Status),
+		StatusReason:  account.StatusReason,
+	}, nil
+}
+
+func (s *GrpcServer) TransferFunds(ctx context.Context, req *pb.TransferFundsRequest) (*pb.TransferFundsResponse, error) {
+	return idempotent(ctx, s, pb.CommerceTransactions_TransferFunds_FullMethodName, req.IdempotencyKey, req, func() (*pb.TransferFundsResponse, error) {
+		fromId, err := uuid.Parse(req.FromId)
+		if err != nil {
+			return nil, invalidID("from_id", err)
+		}
+		toId, err := uuid.Parse(req.ToId)
+		if err != nil {
+			return nil, invalidID("to_id", err)
+		}
 
-	var balance int32
-	if err := row.Scan(&balance); err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, status.Errorf(codes.NotFound, "failed to find transaction")
+		amount, err := moneyFromProto("amount", req.Amount)
+		if err != nil {
+			return nil, err
 		}
+		var converted *money.Money
+		if req.ConvertedAmount != nil {
+			convertedAmount, err := moneyFromProto("converted_amount", req.ConvertedAmount)
+			if err != nil {
+				return nil, err
+			}
+			converted = &convertedAmount
+		}
+
+		from, to, err := s.repo.Transfer(ctx, fromId, toId, amount, converted)
+		if err != nil {
+			return nil, fmt.Errorf("transferring funds: %w", err)
+		}
+		s.metrics.ObserveTransfer(amount)
 
-		slog.Error("failed to get transaction", "error", err)
+		return &pb.TransferFundsResponse{
+			Success:     true,
+			Message:     "Funds transferred successfully",
+			FromBalance: moneyToProto(from.Money()),
+			ToBalance:   moneyToProto(to.Money()),
+		}, nil
+	})
+}
 
-		return nil, status.Errorf(codes.Internal, "failed to get transaction: %v", err)
+func (s *GrpcServer) ListTransactions(ctx context.Context, req *pb.ListTransactionsRequest) (*pb.ListTransactionsResponse, error) {
+	pageSize := int(req.PageSize)
+	switch {
+	case pageSize < 0:
+		return nil, domain.ErrInvalidPageSize.With("field", "page_size")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
 	}
 
-	return &pb.GetTransactionResponse{
-		Balance:       balance,
-		TransactionId: req.TransactionId,
-	}, nil
+	opts := repository.ListOptions{
+		// Fetch one extra row to learn whether another page follows.
+		Limit:      pageSize + 1,
+		Descending: req.Order == pb.SortOrder_SORT_ORDER_CREATED_DESC,
+	}
+	if req.Currency != "" {
+		if err := money.ValidateCurrency(req.Currency); err != nil {
+			return nil, currencyError("currency", req.Currency, err)
+		}
+		opts.Currency = req.Currency
+	}
+	opts.MinBalance = req.MinBalance
+	opts.MaxBalance = req.MaxBalance
+	if opts.MinBalance != nil && opts.MaxBalance != nil && *opts.MinBalance > *opts.MaxBalance {
+		return nil, fmt.Errorf("%w: min_balance must not exceed max_balance", domain.ErrInvalidArgument.With("field", "min_balance"))
+	}
+	if req.CreatedAfter != nil {
+		opts.CreatedAfter = req.CreatedAfter.AsTime()
+	}
+	if req.CreatedBefore != nil {
+		opts.CreatedBefore = req.CreatedBefore.AsTime()
+	}
+	// The token is checked against the filters it continues, so decode it
+	// only once they are all set.
+	after, err := decodePageToken(req.PageToken, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidPageToken.With("field", "page_token"), err)
+	}
+	opts.After = after
+
+	accounts, err := s.repo.List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("listing transactions: %w", err)
+	}
+
+	res := &pb.ListTransactionsResponse{}
+	if len(accounts) > pageSize {
+		accounts = accounts[:pageSize]
+		res.NextPageToken = encodePageToken(repository.CursorOf(accounts[pageSize-1]), opts)
+	}
+	for _, account := range accounts {
+		res.Transactions = append(res.Transactions, transactionToProto(account))
+	}
+	return res, nil
+}
+
+func (s *GrpcServer) WatchTransactions(req *pb.WatchTransactionsRequest, stream pb.CommerceTransactions_WatchTransactionsServer) error {
+	var cursor watch.Cursor
+	if req.Cursor != "" {
+		var err error
+		if cursor, err = watch.ParseCursor(req.Cursor); err != nil {
+			return fmt.Errorf("%w: %v", domain.ErrInvalidCursor.With("field", "cursor"), err)
+		}
+	}
+	filter := make(map[uuid.UUID]bool, len(req.TransactionIds))
+	for _, id := range req.TransactionIds {
+		transactionId, err := uuid.Parse(id)
+		if err != nil {
+			return invalidID("transaction_ids", err)
+		}
+		filter[transactionId] = true
+	}
+
+	// The source sees every tenant, so filter by the caller's scope.
+	scope, scoped := repository.ScopeFrom(stream.Context())
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	events := make(chan watch.Event, 64)
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- s.watch.Watch(ctx, cursor, events)
+	}()
+
+	for {
+		select {
+		case event := <-events:
+			if event.Type != watch.Checkpoint {
+				if len(filter) > 0 && !filter[event.Account.ID] {
+					continue
+				}
+				if scoped && !scope.Includes(event.Account) {
+					continue
+				}
+			}
+			if err := stream.Send(transactionEventToProto(event)); err != nil {
+				return err
+			}
+		case err := <-watchErr:
+			if stream.Context().Err() != nil {
+				return status.FromContextError(stream.Context().Err()).Err()
+			}
+			logging.FromContext(ctx).Error("failed to watch transactions", "error", err)
+			return status.Errorf(codes.Unavailable, "transaction watch interrupted, resume from the last cursor")
+		case <-s.shutdown:
+			return status.Errorf(codes.Unavailable, "server is shutting down, resume from the last cursor")
+		}
+	}
+}
+
+// invalidID reports an ID in the request field that is not a UUID.
+func invalidID(field string, err error) error {
+	return fmt.Errorf("%w in %s: %v", domain.ErrInvalidID.With("field", field), field, err)
+}
+
+func transactionToProto(account repository.Account) *pb.Transaction {
+	return &pb.Transaction{
+		TransactionId: account.ID.String(),
+		Balance:       moneyToProto(account.Money()),
+		CreatedAt:     timestamppb.New(account.CreatedAt),
+		Version:       account.Version,
+		TenantId:      account.TenantID,
+		OwnerId:       account.OwnerID,
+		Status:        statusToProto(account.Status),
+	}
+}
+
+func transactionEventToProto(event watch.Event) *pb.TransactionEvent {
+	res := &pb.TransactionEvent{
+		Cursor:    string(event.Cursor),
+		Timestamp: timestamppb.New(event.Cursor.Time()),
+	}
+	switch event.Type {
+	case watch.Created:
+		res.Type = pb.TransactionEvent_TYPE_CREATED
+		res.Transaction = transactionToProto(event.Account)
+	case watch.Updated:
+		res.Type = pb.TransactionEvent_TYPE_UPDATED
+		res.Transaction = transactionToProto(event.Account)
+	case watch.Deleted:
+		res.Type = pb.TransactionEvent_TYPE_DELETED
+		res.Transaction = &pb.Transaction{TransactionId: event.Account.ID.String()}
+	case watch.Checkpoint:
+		res.Type = pb.TransactionEvent_TYPE_CHECKPOINT
+	}
+	return res
+}
+
+// newWatchSource builds the change source selected by cfg.WATCH_SOURCE.
+func newWatchSource(cfg *config.Config, pool *pgxpool.Pool, repo repository.AccountRepository) (watch.Source, error) {
+	changefeed := watch.NewChangefeedSource(pool, cfg.WATCH_RESOLVED_INTERVAL)
+	poll := watch.NewPollSource(repo, cfg.WATCH_POLL_INTERVAL)
+	switch cfg.WATCH_SOURCE {
+	case "changefeed":
+		return changefeed, nil
+	case "poll":
+		return poll, nil
+	case "auto":
+		return watch.NewFallbackSource(changefeed, poll), nil
+	}
+	return nil, fmt.Errorf("unknown WATCH_SOURCE %q, want auto, changefeed or poll", cfg.WATCH_SOURCE)
+}
+
+// newOutboxSink builds the outbox sink selected by cfg.OUTBOX_SINK. The
+// channel sink delivers to events.
+func newOutboxSink(cfg *config.Config, events chan<- outbox.Event) (outbox.Sink, error) {
+	switch cfg.OUTBOX_SINK {
+	case "channel":
+		return outbox.NewChannelSink(events), nil
+	case "file":
+		return outbox.NewFileSink(cfg.OUTBOX_FILE)
+	case "webhook":
+		if cfg.OUTBOX_WEBHOOK_URL == "" {
+			return nil, errors.New("OUTBOX_WEBHOOK_URL is required for the webhook sink")
+		}
+		return outbox.NewWebhookSink(cfg.OUTBOX_WEBHOOK_URL, 10*time.Second), nil
+	}
+	return nil, fmt.Errorf("unknown OUTBOX_SINK %q, want file, webhook or channel", cfg.OUTBOX_SINK)
+}
+
+// logOutboxEvents is the in-process consumer of the channel sink. It logs
+// each event until ctx is cancelled.
+func logOutboxEvents(ctx context.Context, events <-chan outbox.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			slog.Info("outbox event", "id", event.ID, "type", event.Type, "account_id", event.AggregateID, "payload", string(event.Payload))
+		}
+	}
 }
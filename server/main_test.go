@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yaninyzwitty/golang-proj-with-db/domain"
+	"github.com/yaninyzwitty/golang-proj-with-db/idempotency"
+	"github.com/yaninyzwitty/golang-proj-with-db/metrics"
+	"github.com/yaninyzwitty/golang-proj-with-db/money"
+	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+	"github.com/yaninyzwitty/golang-proj-with-db/validation"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestClient serves a GrpcServer backed by a MemoryRepository behind the
+// validation and domain error interceptors, as main does, and returns a
+// client connected to it.
+func newTestClient(t *testing.T) pb.CommerceTransactionsClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(validation.UnaryServerInterceptor(), domain.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(validation.StreamServerInterceptor(), domain.StreamServerInterceptor()))
+	pb.RegisterCommerceTransactionsServer(server, &GrpcServer{
+		repo:             repository.NewMemoryRepository(),
+		idempotency:      idempotency.NewMemoryStore(),
+		idempotencyTTL:   time.Hour,
+		idempotencyLease: time.Minute,
+		metrics:          metrics.New(),
+	})
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewCommerceTransactionsClient(conn)
+}
+
+// wantError fails the test unless err is a status with code and an
+// ErrorInfo of reason.
+func wantError(t *testing.T, err error, code codes.Code, reason string) {
+	t.Helper()
+	st := status.Convert(err)
+	if st.Code() != code {
+		t.Errorf("error = %v, want code %v", err, code)
+		return
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			if info.Reason != reason || info.Domain != domain.ErrorDomain {
+				t.Errorf("error = %v with reason %s/%s, want %s", err, info.Domain, info.Reason, reason)
+			}
+			return
+		}
+	}
+	t.Errorf("error = %v without ErrorInfo, want reason %s", err, reason)
+}
+
+func usd(amount int64) *pb.Money {
+	return &pb.Money{Currency: "USD", Amount: amount}
+}
+
+func createTransaction(t *testing.T, client pb.CommerceTransactionsClient, balance *pb.Money) string {
+	t.Helper()
+	res, err := client.CreateTransaction(context.Background(), &pb.CreateTransactionRequest{Balance: balance})
+	if err != nil {
+		t.Fatalf("CreateTransaction: %v", err)
+	}
+	return res.TransactionId
+}
+
+func TestCreateGetUpdateTransaction(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	created, err := client.CreateTransaction(ctx, &pb.CreateTransactionRequest{Balance: usd(100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Status != pb.TransactionStatus_TRANSACTION_STATUS_ACTIVE {
+		t.Errorf("created status = %v, want ACTIVE", created.Status)
+	}
+	got, err := client.GetTransaction(ctx, &pb.GetTransactionRequest{TransactionId: created.TransactionId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Balance.Amount != 100 || got.Balance.Currency != "USD" || got.Version != created.Version {
+		t.Errorf("GetTransaction = %v, want the created transaction %v", got, created)
+	}
+
+	updated, err := client.UpdateTransaction(ctx, &pb.UpdateTransactionRequest{
+		TransactionId:   created.TransactionId,
+		Balance:         usd(250),
+		ExpectedVersion: &created.Version,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Balance.Amount != 250 || updated.Version == created.Version {
+		t.Errorf("UpdateTransaction = %v, want balance 250 at a new version", updated)
+	}
+
+	_, err = client.UpdateTransaction(ctx, &pb.UpdateTransactionRequest{
+		TransactionId:   created.TransactionId,
+		Balance:         usd(300),
+		ExpectedVersion: &created.Version,
+	})
+	wantError(t, err, codes.Aborted, "VERSION_CONFLICT")
+
+	_, err = client.UpdateTransaction(ctx, &pb.UpdateTransactionRequest{
+		TransactionId: created.TransactionId,
+		Balance:       &pb.Money{Currency: "EUR", Amount: 300},
+	})
+	wantError(t, err, codes.FailedPrecondition, "CURRENCY_MISMATCH")
+
+	missing := uuid.NewString()
+	_, err = client.GetTransaction(ctx, &pb.GetTransactionRequest{TransactionId: missing})
+	wantError(t, err, codes.NotFound, "ACCOUNT_NOT_FOUND")
+	_, err = client.UpdateTransaction(ctx, &pb.UpdateTransactionRequest{TransactionId: missing, Balance: usd(1)})
+	wantError(t, err, codes.NotFound, "ACCOUNT_NOT_FOUND")
+}
+
+func TestTransferFunds(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	from := createTransaction(t, client, usd(100))
+	to := createTransaction(t, client, usd(0))
+
+	res, err := client.TransferFunds(ctx, &pb.TransferFundsRequest{FromId: from, ToId: to, Amount: usd(40)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.FromBalance.Amount != 60 || res.ToBalance.Amount != 40 {
+		t.Errorf("balances after transfer = %d and %d, want 60 and 40", res.FromBalance.Amount, res.ToBalance.Amount)
+	}
+
+	_, err = client.TransferFunds(ctx, &pb.TransferFundsRequest{FromId: from, ToId: to, Amount: usd(61)})
+	wantError(t, err, codes.FailedPrecondition, "INSUFFICIENT_FUNDS")
+	_, err = client.TransferFunds(ctx, &pb.TransferFundsRequest{FromId: from, ToId: uuid.NewString(), Amount: usd(1)})
+	wantError(t, err, codes.NotFound, "ACCOUNT_NOT_FOUND")
+	_, err = client.TransferFunds(ctx, &pb.TransferFundsRequest{FromId: from, ToId: from, Amount: usd(1)})
+	wantError(t, err, codes.InvalidArgument, "SAME_ACCOUNT")
+	_, err = client.TransferFunds(ctx, &pb.TransferFundsRequest{FromId: from, ToId: to, Amount: usd(0)})
+	wantError(t, err, codes.InvalidArgument, "INVALID_AMOUNT")
+
+	euros := createTransaction(t, client, &pb.Money{Currency: "EUR", Amount: 0})
+	_, err = client.TransferFunds(ctx, &pb.TransferFundsRequest{FromId: from, ToId: euros, Amount: usd(10)})
+	wantError(t, err, codes.FailedPrecondition, "CONVERSION_REQUIRED")
+	res, err = client.TransferFunds(ctx, &pb.TransferFundsRequest{
+		FromId:          from,
+		ToId:            euros,
+		Amount:          usd(10),
+		ConvertedAmount: &pb.Money{Currency: "EUR", Amount: 9},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.FromBalance.Amount != 50 || res.ToBalance.Amount != 9 {
+		t.Errorf("balances after conversion = %d and %d, want 50 and 9", res.FromBalance.Amount, res.ToBalance.Amount)
+	}
+}
+
+func TestDeleteAndRestoreTransaction(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	id := createTransaction(t, client, usd(100))
+
+	_, err := client.DeleteTransaction(ctx, &pb.DeleteTransactionRequest{TransactionId: id})
+	wantError(t, err, codes.FailedPrecondition, "BALANCE_NOT_ZERO")
+	if _, err := client.GetTransaction(ctx, &pb.GetTransactionRequest{TransactionId: id}); err != nil {
+		t.Fatalf("refused delete removed the transaction: %v", err)
+	}
+
+	if _, err := client.DeleteTransaction(ctx, &pb.DeleteTransactionRequest{TransactionId: id, Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.GetTransaction(ctx, &pb.GetTransactionRequest{TransactionId: id})
+	wantError(t, err, codes.NotFound, "ACCOUNT_NOT_FOUND")
+
+	restored, err := client.RestoreTransaction(ctx, &pb.RestoreTransactionRequest{TransactionId: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Balance.Amount != 0 {
+		t.Errorf("restored balance = %d, want 0 after a forced delete", restored.Balance.Amount)
+	}
+	_, err = client.RestoreTransaction(ctx, &pb.RestoreTransactionRequest{TransactionId: id})
+	wantError(t, err, codes.FailedPrecondition, "ACCOUNT_NOT_DELETED")
+
+	// An empty transaction is deleted without force.
+	empty := createTransaction(t, client, usd(0))
+	if _, err := client.DeleteTransaction(ctx, &pb.DeleteTransactionRequest{TransactionId: empty}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.DeleteTransaction(ctx, &pb.DeleteTransactionRequest{TransactionId: empty})
+	wantError(t, err, codes.NotFound, "ACCOUNT_NOT_FOUND")
+}
+
+func TestSetTransactionStatus(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	created, err := client.CreateTransaction(ctx, &pb.CreateTransactionRequest{Balance: usd(100), Pending: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.TransactionId
+	setStatus := func(s pb.TransactionStatus) (*pb.TransactionResponse, error) {
+		return client.SetTransactionStatus(ctx, &pb.SetTransactionStatusRequest{TransactionId: id, Status: s, Reason: "test"})
+	}
+
+	_, err = client.UpdateTransaction(ctx, &pb.UpdateTransactionRequest{TransactionId: id, Balance: usd(1)})
+	wantError(t, err, codes.FailedPrecondition, "ACCOUNT_PENDING")
+
+	res, err := setStatus(pb.TransactionStatus_TRANSACTION_STATUS_ACTIVE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != pb.TransactionStatus_TRANSACTION_STATUS_ACTIVE {
+		t.Errorf("status = %v, want ACTIVE", res.Status)
+	}
+
+	if _, err := setStatus(pb.TransactionStatus_TRANSACTION_STATUS_FROZEN); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.GetTransaction(ctx, &pb.GetTransactionRequest{TransactionId: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != pb.TransactionStatus_TRANSACTION_STATUS_FROZEN || got.StatusReason != "test" {
+		t.Errorf("GetTransaction = %v, want FROZEN with the reason", got)
+	}
+	_, err = client.UpdateTransaction(ctx, &pb.UpdateTransactionRequest{TransactionId: id, Balance: usd(1)})
+	wantError(t, err, codes.FailedPrecondition, "ACCOUNT_FROZEN")
+	_, err = setStatus(pb.TransactionStatus_TRANSACTION_STATUS_PENDING)
+	wantError(t, err, codes.FailedPrecondition, "INVALID_STATUS_TRANSITION")
+
+	if _, err := setStatus(pb.TransactionStatus_TRANSACTION_STATUS_ACTIVE); err != nil {
+		t.Fatal(err)
+	}
+	_, err = setStatus(pb.TransactionStatus_TRANSACTION_STATUS_CLOSED)
+	wantError(t, err, codes.FailedPrecondition, "BALANCE_NOT_ZERO")
+
+	_, err = client.SetTransactionStatus(ctx, &pb.SetTransactionStatusRequest{TransactionId: uuid.NewString(), Status: pb.TransactionStatus_TRANSACTION_STATUS_FROZEN, Reason: "test"})
+	wantError(t, err, codes.NotFound, "ACCOUNT_NOT_FOUND")
+}
+
+func TestInvalidArgumentsReachClient(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	minBalance, maxBalance := int64(10), int64(5)
+
+	_, err := client.CreateTransaction(ctx, &pb.CreateTransactionRequest{Balance: &pb.Money{Currency: "XYZ", Amount: 1}})
+	wantError(t, err, codes.InvalidArgument, "INVALID_CURRENCY")
+	_, err = client.TransferFunds(ctx, &pb.TransferFundsRequest{FromId: uuid.NewString(), ToId: uuid.NewString(), Amount: &pb.Money{Currency: "usd", Amount: 1}})
+	wantError(t, err, codes.InvalidArgument, "INVALID_CURRENCY")
+	_, err = client.ListTransactions(ctx, &pb.ListTransactionsRequest{Currency: "XYZ"})
+	wantError(t, err, codes.InvalidArgument, "INVALID_CURRENCY")
+	_, err = client.ListTransactions(ctx, &pb.ListTransactionsRequest{MinBalance: &minBalance, MaxBalance: &maxBalance})
+	wantError(t, err, codes.InvalidArgument, "INVALID_ARGUMENT")
+	_, err = client.ListTransactions(ctx, &pb.ListTransactionsRequest{PageToken: "not-a-token"})
+	wantError(t, err, codes.InvalidArgument, "INVALID_PAGE_TOKEN")
+}
+
+func TestListTransactionsPageSize(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	// Called directly, without the validation interceptor that would
+	// reject these sizes first.
+	s := &GrpcServer{repo: repo}
+	ctx := context.Background()
+	for i := 0; i < maxPageSize+1; i++ {
+		if _, err := repo.Create(ctx, money.New("USD", 1), repository.StatusActive); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := s.ListTransactions(ctx, &pb.ListTransactionsRequest{PageSize: -1})
+	if !errors.Is(err, domain.ErrInvalidPageSize) {
+		t.Errorf("negative page size error = %v, want ErrInvalidPageSize", err)
+	}
+
+	res, err := s.ListTransactions(ctx, &pb.ListTransactionsRequest{PageSize: maxPageSize * 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Transactions) != maxPageSize || res.NextPageToken == "" {
+		t.Errorf("oversized page has %d transactions and next page token %q, want %d and a token", len(res.Transactions), res.NextPageToken, maxPageSize)
+	}
+
+	res, err = s.ListTransactions(ctx, &pb.ListTransactionsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Transactions) != defaultPageSize {
+		t.Errorf("default page has %d transactions, want %d", len(res.Transactions), defaultPageSize)
+	}
+}
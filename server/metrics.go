@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// serveMetrics serves handler on /metrics until ctx is cancelled.
+func serveMetrics(ctx context.Context, lis net.Listener, handler http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("serving metrics", "address", lis.Addr().String())
+	if err := server.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("failed to serve metrics", "error", err)
+	}
+}
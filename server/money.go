@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/domain"
+	"github.com/yaninyzwitty/golang-proj-with-db/money"
+	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+)
+
+// moneyFromProto converts a request amount, rejecting missing amounts with
+// ErrMissingField and unknown currencies with ErrInvalidCurrency. field
+// names the request field in the error.
+func moneyFromProto(field string, m *pb.Money) (money.Money, error) {
+	if m == nil {
+		return money.Money{}, fmt.Errorf("%w: %s", domain.ErrMissingField.With("field", field), field)
+	}
+	amount := money.New(m.Currency, m.Amount)
+	if err := amount.Validate(); err != nil {
+		return money.Money{}, currencyError(field, m.Currency, err)
+	}
+	return amount, nil
+}
+
+// currencyError converts money.ErrUnknownCurrency for currency, given in
+// the request field, to ErrInvalidCurrency. Other errors are returned as
+// they are.
+func currencyError(field, currency string, err error) error {
+	if !errors.Is(err, money.ErrUnknownCurrency) {
+		return err
+	}
+	return fmt.Errorf("%w %q in %s", domain.ErrInvalidCurrency.With("field", field).With("currency", currency), currency, field)
+}
+
+func moneyToProto(m money.Money) *pb.Money {
+	return &pb.Money{Currency: m.Currency, Amount: m.Amount}
+}
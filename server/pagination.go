@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+)
+
+// defaultPageSize applies when page_size is zero, and larger page sizes
+// are capped at maxPageSize. The validation interceptor rejects them
+// before they get here, but the handler must not depend on it.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// pageToken is the decoded form of the opaque page_token handed to clients.
+// It records a hash of the filters and sort order it was issued under, so
+// a token cannot be replayed against a different query and silently skip
+// or repeat rows.
+type pageToken struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	Query     string    `json:"q"`
+}
+
+// queryHash identifies the filters and order of opts, ignoring the page
+// position and size.
+func queryHash(opts repository.ListOptions) string {
+	raw, _ := json.Marshal(struct {
+		Descending    bool
+		Currency      string
+		MinBalance    *int64
+		MaxBalance    *int64
+		CreatedAfter  time.Time
+		CreatedBefore time.Time
+	}{
+		Descending:    opts.Descending,
+		Currency:      opts.Currency,
+		MinBalance:    opts.MinBalance,
+		MaxBalance:    opts.MaxBalance,
+		CreatedAfter:  opts.CreatedAfter.UTC(),
+		CreatedBefore: opts.CreatedBefore.UTC(),
+	})
+	sum := sha256.Sum256(raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// encodePageToken returns the token that continues the query of opts
+// after cursor.
+func encodePageToken(cursor *repository.Cursor, opts repository.ListOptions) string {
+	raw, _ := json.Marshal(pageToken{CreatedAt: cursor.CreatedAt, ID: cursor.ID, Query: queryHash(opts)})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodePageToken returns the cursor of token, which must have been issued
+// for the same filters and order as opts. The empty token starts at the
+// first page.
+func decodePageToken(token string, opts repository.ListOptions) (*repository.Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("malformed page token")
+	}
+	var decoded pageToken
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, fmt.Errorf("malformed page token")
+	}
+	if decoded.Query != queryHash(opts) {
+		return nil, fmt.Errorf("page token was issued for different filters or sort order")
+	}
+	return &repository.Cursor{CreatedAt: decoded.CreatedAt, ID: decoded.ID}, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+)
+
+func TestPageTokenRoundTrip(t *testing.T) {
+	minBalance := int64(100)
+	opts := repository.ListOptions{
+		Descending:   true,
+		Currency:     "USD",
+		MinBalance:   &minBalance,
+		CreatedAfter: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	cursor := &repository.Cursor{CreatedAt: time.Date(2024, 3, 1, 12, 0, 0, 123000, time.UTC), ID: uuid.New()}
+
+	token := encodePageToken(cursor, opts)
+	// The page size and position are not part of the query.
+	opts.Limit = 11
+	opts.After = cursor
+	decoded, err := decodePageToken(token, opts)
+	if err != nil {
+		t.Fatalf("decodePageToken: %v", err)
+	}
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+		t.Errorf("decodePageToken = %+v, want %+v", decoded, cursor)
+	}
+
+	if decoded, err := decodePageToken("", opts); decoded != nil || err != nil {
+		t.Errorf("decodePageToken(\"\") = %v, %v, want nil, nil", decoded, err)
+	}
+}
+
+func TestPageTokenRejectsOtherQueries(t *testing.T) {
+	minBalance, otherBalance := int64(100), int64(101)
+	opts := repository.ListOptions{Currency: "USD", MinBalance: &minBalance}
+	token := encodePageToken(&repository.Cursor{CreatedAt: time.Now(), ID: uuid.New()}, opts)
+
+	for name, changed := range map[string]repository.ListOptions{
+		"order":          {Currency: "USD", MinBalance: &minBalance, Descending: true},
+		"currency":       {Currency: "EUR", MinBalance: &minBalance},
+		"no currency":    {MinBalance: &minBalance},
+		"min balance":    {Currency: "USD", MinBalance: &otherBalance},
+		"max balance":    {Currency: "USD", MinBalance: &minBalance, MaxBalance: &otherBalance},
+		"created after":  {Currency: "USD", MinBalance: &minBalance, CreatedAfter: time.Unix(1, 0)},
+		"created before": {Currency: "USD", MinBalance: &minBalance, CreatedBefore: time.Unix(1, 0)},
+	} {
+		if _, err := decodePageToken(token, changed); err == nil {
+			t.Errorf("token accepted with a different %s", name)
+		}
+	}
+}
+
+func TestPageTokenRejectsTampering(t *testing.T) {
+	opts := repository.ListOptions{Currency: "USD"}
+	token := encodePageToken(&repository.Cursor{CreatedAt: time.Now(), ID: uuid.New()}, opts)
+	raw, _ := base64.RawURLEncoding.DecodeString(token)
+	var decoded pageToken
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	decoded.Query = queryHash(repository.ListOptions{})
+	forged, _ := json.Marshal(decoded)
+
+	for name, token := range map[string]string{
+		"not base64":   "!!!",
+		"not JSON":     base64.RawURLEncoding.EncodeToString([]byte("cursor")),
+		"no query":     base64.RawURLEncoding.EncodeToString([]byte(`{"t":"2024-01-01T00:00:00Z","id":"` + uuid.NewString() + `"}`)),
+		"forged query": base64.RawURLEncoding.EncodeToString(forged),
+		"truncated":    token[:len(token)-4],
+	} {
+		if _, err := decodePageToken(token, opts); err == nil {
+			t.Errorf("%s token accepted", name)
+		}
+	}
+}
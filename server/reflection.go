@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// registerReflection serves the v1 and v1alpha reflection services. Our
+// own descriptors come from the embedded pb.DescriptorSet; anything else,
+// such as the health service, from the descriptors linked into the binary.
+func registerReflection(server *grpc.Server) error {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(pb.DescriptorSet, &set); err != nil {
+		return err
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return err
+	}
+
+	opts := reflection.ServerOptions{
+		Services:           server,
+		DescriptorResolver: fallbackResolver{files, protoregistry.GlobalFiles},
+	}
+	reflectionv1.RegisterServerReflectionServer(server, reflection.NewServerV1(opts))
+	reflectionv1alpha.RegisterServerReflectionServer(server, reflection.NewServer(opts))
+	return nil
+}
+
+// fallbackResolver looks descriptors up in primary, then in fallback.
+type fallbackResolver struct {
+	primary, fallback protodesc.Resolver
+}
+
+func (r fallbackResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	file, err := r.primary.FindFileByPath(path)
+	if errors.Is(err, protoregistry.NotFound) {
+		return r.fallback.FindFileByPath(path)
+	}
+	return file, err
+}
+
+func (r fallbackResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	descriptor, err := r.primary.FindDescriptorByName(name)
+	if errors.Is(err, protoregistry.NotFound) {
+		return r.fallback.FindDescriptorByName(name)
+	}
+	return descriptor, err
+}
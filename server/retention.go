@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+)
+
+// purgeDeletedAccounts periodically removes accounts that were deleted
+// longer than retention ago, after which they can no longer be restored.
+func purgeDeletedAccounts(ctx context.Context, repo repository.AccountRepository, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := repo.PurgeDeleted(ctx, time.Now().Add(-retention))
+			if err != nil {
+				slog.Error("failed to purge deleted accounts", "error", err)
+				continue
+			}
+			if purged > 0 {
+				slog.Info("purged deleted accounts", "count", purged)
+			}
+		}
+	}
+}
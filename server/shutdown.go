@@ -0,0 +1,28 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// gracefulStop stops accepting connections and waits for in-flight RPCs to
+// finish. Whatever is still running after timeout is cancelled.
+func gracefulStop(server *grpc.Server, timeout time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-timer.C:
+		slog.Warn("graceful shutdown timed out, cancelling remaining RPCs")
+		server.Stop()
+		<-stopped
+	}
+}
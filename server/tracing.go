@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/config"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// serviceName identifies this server in traces.
+const serviceName = "commerce-transactions"
+
+// newSpanExporter returns the exporter selected by cfg.TRACING_EXPORTER, or
+// nil if tracing is disabled.
+func newSpanExporter(ctx context.Context, cfg *config.Config) (sdktrace.SpanExporter, error) {
+	switch cfg.TRACING_EXPORTER {
+	case "none":
+		return nil, nil
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.TRACING_OTLP_ENDPOINT)}
+		if cfg.TRACING_OTLP_INSECURE {
+			options = append(options, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, options...)
+	}
+	return nil, fmt.Errorf("unknown TRACING_EXPORTER %q, want none, stdout or otlp", cfg.TRACING_EXPORTER)
+}
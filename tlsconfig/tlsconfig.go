@@ -0,0 +1,257 @@
+// Package tlsconfig builds the TLS configuration of the server and client.
+// The server's certificate, key and CA bundle are reloaded when their files
+// change, so certificates can be rotated without a restart.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Reloader holds a certificate and CA bundle loaded from files, and
+// reloads them when the files' modification times change.
+type Reloader struct {
+	certFile, keyFile, caFile string
+
+	current atomic.Pointer[material]
+}
+
+// material is one consistent load of the files.
+type material struct {
+	certificate *tls.Certificate
+	// pool is nil when no CA file is configured.
+	pool     *x509.CertPool
+	modTimes []time.Time
+}
+
+// NewReloader loads certFile and keyFile, and the PEM CA bundle caFile if
+// it is not empty.
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	m, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	r.current.Store(m)
+	return r, nil
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
+
+func (r *Reloader) modTimes() ([]time.Time, error) {
+	var modTimes []time.Time
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
+
+func (r *Reloader) load() (*material, error) {
+	// Read the times first: a file replaced during the load then looks
+	// changed on the next poll and is loaded again.
+	modTimes, err := r.modTimes()
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading certificate: %w", err)
+	}
+	m := &material{certificate: &certificate, modTimes: modTimes}
+	if r.caFile != "" {
+		if m.pool, err = loadPool(r.caFile); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Run checks the files every interval and reloads them when any has
+// changed, until ctx is cancelled. A failed reload keeps the previous
+// certificates and is retried on the next change.
+func (r *Reloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reloadIfChanged()
+		}
+	}
+}
+
+func (r *Reloader) reloadIfChanged() {
+	modTimes, err := r.modTimes()
+	if err != nil {
+		slog.Error("failed to check TLS files", "error", err)
+		return
+	}
+	previous := r.current.Load()
+	if equalTimes(modTimes, previous.modTimes) {
+		return
+	}
+	m, err := r.load()
+	if err != nil {
+		slog.Error("failed to reload TLS files, keeping the previous certificates", "error", err)
+		// Remember the new times so a broken file is not reloaded on
+		// every poll.
+		stale := *previous
+		stale.modTimes = modTimes
+		r.current.Store(&stale)
+		return
+	}
+	r.current.Store(m)
+	slog.Info("reloaded TLS certificates", "cert_file", r.certFile)
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ServerConfig returns a server configuration that always presents the
+// current certificate. With requireClientCert, clients must present a
+// certificate signed by the CA bundle.
+func (r *Reloader) ServerConfig(requireClientCert bool) (*tls.Config, error) {
+	if requireClientCert && r.caFile == "" {
+		return nil, fmt.Errorf("requiring client certificates needs a CA file to verify them")
+	}
+	clientAuth := tls.NoClientCert
+	if requireClientCert {
+		clientAuth = tls.RequireAndVerifyClientCert
+	} else if r.caFile != "" {
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			m := r.current.Load()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*m.certificate},
+				ClientCAs:    m.pool,
+				ClientAuth:   clientAuth,
+				// gRPC only adds its ALPN protocol to the outer config.
+				NextProtos: []string{"h2"},
+			}, nil
+		},
+	}, nil
+}
+
+// ClientConfig returns a client configuration for a short-lived process:
+// the files are read once. certFile and keyFile, if set, are presented to
+// servers that ask for a client certificate; caFile, if set, replaces the
+// system roots; serverName, if set, overrides the name verified.
+func ClientConfig(certFile, keyFile, caFile, serverName string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: serverName}
+	if certFile != "" || keyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	if caFile != "" {
+		pool, err := loadPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+func loadPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return pool, nil
+}
+
+// Identity is the verified client certificate of an RPC.
+type Identity struct {
+	CommonName string
+	DNSNames   []string
+	// URIs holds URI SANs, such as SPIFFE IDs.
+	URIs []string
+	// Certificate is the verified leaf certificate.
+	Certificate *x509.Certificate
+}
+
+// Name identifies the client for logs: its first URI SAN, or else its
+// common name, or else its first DNS name.
+func (i Identity) Name() string {
+	switch {
+	case len(i.URIs) > 0:
+		return i.URIs[0]
+	case i.CommonName != "":
+		return i.CommonName
+	case len(i.DNSNames) > 0:
+		return i.DNSNames[0]
+	}
+	return ""
+}
+
+// PeerIdentity returns the identity of the client of the RPC in ctx. ok is
+// false unless the client presented a certificate that was verified
+// against the CA bundle.
+func PeerIdentity(ctx context.Context) (identity Identity, ok bool) {
+	p, found := peer.FromContext(ctx)
+	if !found {
+		return Identity{}, false
+	}
+	info, isTLS := p.AuthInfo.(credentials.TLSInfo)
+	if !isTLS {
+		return Identity{}, false
+	}
+	return identityFromState(info.State)
+}
+
+func identityFromState(state tls.ConnectionState) (Identity, bool) {
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return Identity{}, false
+	}
+	leaf := state.VerifiedChains[0][0]
+	identity := Identity{
+		CommonName:  leaf.Subject.CommonName,
+		DNSNames:    leaf.DNSNames,
+		Certificate: leaf,
+	}
+	for _, uri := range leaf.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+	}
+	return identity, true
+}
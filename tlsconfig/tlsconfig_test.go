@@ -0,0 +1,181 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate for commonName, valid for localhost, and its
+// key to dir, returning their paths.
+func (ca *testCA) issue(t *testing.T, dir, commonName string, serial int64) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, commonName+".crt")
+	keyFile = filepath.Join(dir, commonName+".key")
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certFile, keyFile
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// handshake connects a client with clientConfig to a server with
+// serverConfig and returns the server's view of the connection and the
+// serial of the certificate the server presented.
+func handshake(t *testing.T, serverConfig, clientConfig *tls.Config) (tls.ConnectionState, int64) {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	client := tls.Client(clientConn, clientConfig)
+	clientErr := make(chan error, 1)
+	go func() { clientErr <- client.Handshake() }()
+	server := tls.Server(serverConn, serverConfig)
+	if err := server.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-clientErr; err != nil {
+		t.Fatal(err)
+	}
+	return server.ConnectionState(), client.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+}
+
+func TestMutualTLSAndReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCA(t)
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, caFile, ca.pem)
+	serverCert, serverKey := ca.issue(t, dir, "server", 10)
+	clientCert, clientKey := ca.issue(t, dir, "client", 20)
+
+	reloader, err := NewReloader(serverCert, serverKey, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig, err := reloader.ServerConfig(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientConfig, err := ClientConfig(clientCert, clientKey, caFile, "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, serial := handshake(t, serverConfig, clientConfig)
+	if serial != 10 {
+		t.Fatalf("server presented serial %d, want 10", serial)
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{State: state}})
+	identity, ok := PeerIdentity(ctx)
+	if !ok || identity.CommonName != "client" || identity.Name() != "client" {
+		t.Fatalf("peer identity = %+v, %v; want client", identity, ok)
+	}
+
+	// Rotate the server certificate in place.
+	ca.issue(t, dir, "server", 11)
+	later := time.Now().Add(time.Minute)
+	for _, file := range []string{serverCert, serverKey} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reloader.reloadIfChanged()
+	if _, serial := handshake(t, serverConfig, clientConfig); serial != 11 {
+		t.Fatalf("server presented serial %d after reload, want 11", serial)
+	}
+
+	// A broken file keeps the previous certificate.
+	writeFile(t, serverKey, []byte("not a key"))
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(serverKey, later, later); err != nil {
+		t.Fatal(err)
+	}
+	reloader.reloadIfChanged()
+	if _, serial := handshake(t, serverConfig, clientConfig); serial != 11 {
+		t.Fatalf("server presented serial %d after a failed reload, want 11", serial)
+	}
+}
+
+func TestRequireClientCertNeedsCA(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := newCA(t).issue(t, dir, "server", 1)
+	reloader, err := NewReloader(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloader.ServerConfig(true); err == nil {
+		t.Fatal("ServerConfig(true) without a CA file succeeded")
+	}
+}
@@ -0,0 +1,46 @@
+// Package tracing sets up OpenTelemetry tracing and traces database access
+// through pgx.
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans this package creates.
+const instrumentationName = "github.com/yaninyzwitty/golang-proj-with-db/tracing"
+
+// Setup installs a global tracer provider exporting to exporter, and the
+// W3C trace context and baggage propagators. The returned function flushes
+// and stops the exporter.
+func Setup(exporter sdktrace.SpanExporter, serviceName string) func(context.Context) error {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown
+}
+
+// RecordRetry adds an event to the span of ctx for a retried transaction.
+// attempt counts from 1, so it is only recorded from the second attempt,
+// with the error that failed the previous one.
+func RecordRetry(ctx context.Context, attempt int, previous error) {
+	if attempt < 2 {
+		return
+	}
+	attrs := []attribute.KeyValue{attribute.Int("db.transaction.attempt", attempt)}
+	if previous != nil {
+		attrs = append(attrs, attribute.String("error.message", previous.Error()))
+	}
+	trace.SpanFromContext(ctx).AddEvent("transaction retry", trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,106 @@
+// Package validation checks request messages against the rules declared
+// on their fields with the (commerce_transactions.rules) option.
+package validation
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Validate returns an InvalidArgument error with a google.rpc.BadRequest
+// detail listing every field of m that breaks its rules, or nil if m is
+// valid. Set message fields are validated recursively.
+func Validate(m proto.Message) error {
+	violations := check(m.ProtoReflect(), "", nil)
+	if len(violations) == 0 {
+		return nil
+	}
+	st, err := status.New(codes.InvalidArgument, "invalid request: "+violations[0].Field+" "+violations[0].Description).
+		WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid request: %s %s", violations[0].Field, violations[0].Description)
+	}
+	return st.Err()
+}
+
+func check(m protoreflect.Message, prefix string, violations []*errdetails.BadRequest_FieldViolation) []*errdetails.BadRequest_FieldViolation {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		path := prefix + string(field.Name())
+		rules, _ := proto.GetExtension(field.Options(), pb.E_Rules).(*pb.FieldRules)
+
+		if field.IsList() {
+			list := m.Get(field).List()
+			if rules != nil && rules.MaxItems != 0 && list.Len() > int(rules.MaxItems) {
+				violations = appendViolation(violations, path, "must have at most %d elements", rules.MaxItems)
+			}
+			for j := 0; j < list.Len(); j++ {
+				violations = checkValue(field, list.Get(j), rules, path+"["+strconv.Itoa(j)+"]", violations)
+			}
+			continue
+		}
+		if field.IsMap() {
+			continue
+		}
+		if !m.Has(field) {
+			if rules.GetRequired() {
+				violations = appendViolation(violations, path, "is required")
+			} else if field.Kind() != protoreflect.MessageKind && rules != nil {
+				// Unset scalars still hold their zero value, which the
+				// bounds apply to.
+				violations = checkValue(field, m.Get(field), rules, path, violations)
+			}
+			continue
+		}
+		violations = checkValue(field, m.Get(field), rules, path, violations)
+	}
+	return violations
+}
+
+// checkValue checks a single value of field, which is an element for
+// repeated fields.
+func checkValue(field protoreflect.FieldDescriptor, value protoreflect.Value, rules *pb.FieldRules, path string, violations []*errdetails.BadRequest_FieldViolation) []*errdetails.BadRequest_FieldViolation {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return check(value.Message(), path+".", violations)
+	case protoreflect.StringKind:
+		s := value.String()
+		if rules.GetRequired() && s == "" {
+			return appendViolation(violations, path, "is required")
+		}
+		if rules.GetMaxLen() != 0 && len(s) > int(rules.GetMaxLen()) {
+			violations = appendViolation(violations, path, "must be at most %d bytes long", rules.GetMaxLen())
+		}
+		if rules.GetUuid() && s != "" {
+			if _, err := uuid.Parse(s); err != nil {
+				violations = appendViolation(violations, path, "must be a UUID")
+			}
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n := value.Int()
+		if rules != nil && rules.Min != nil && n < *rules.Min {
+			violations = appendViolation(violations, path, "must be at least %d", *rules.Min)
+		}
+		if rules != nil && rules.Max != nil && n > *rules.Max {
+			violations = appendViolation(violations, path, "must be at most %d", *rules.Max)
+		}
+	}
+	return violations
+}
+
+func appendViolation(violations []*errdetails.BadRequest_FieldViolation, field, format string, args ...any) []*errdetails.BadRequest_FieldViolation {
+	return append(violations, &errdetails.BadRequest_FieldViolation{
+		Field:       field,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
@@ -0,0 +1,61 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/pb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const validID = "6f1c3c8e-8f3b-4a5e-9d43-2d7f6f1b9c10"
+
+func TestValidate(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		req    proto.Message
+		fields []string
+	}{
+		{"valid create", &pb.CreateTransactionRequest{Balance: &pb.Money{Currency: "USD", Amount: 100}}, nil},
+		{"missing balance", &pb.CreateTransactionRequest{}, []string{"balance"}},
+		{"negative balance", &pb.CreateTransactionRequest{Balance: &pb.Money{Currency: "USD", Amount: -1}}, []string{"balance.amount"}},
+		{"balance above maximum", &pb.UpdateTransactionRequest{TransactionId: validID, Balance: &pb.Money{Currency: "USD", Amount: 1e15 + 1}}, []string{"balance.amount"}},
+		{"missing currency", &pb.CreateTransactionRequest{Balance: &pb.Money{Amount: 1}}, []string{"balance.currency"}},
+		{"long idempotency key", &pb.CreateTransactionRequest{Balance: &pb.Money{Currency: "USD"}, IdempotencyKey: strings.Repeat("k", 129)}, []string{"idempotency_key"}},
+		{"missing ID", &pb.GetTransactionRequest{}, []string{"transaction_id"}},
+		{"malformed ID", &pb.DeleteTransactionRequest{TransactionId: "42"}, []string{"transaction_id"}},
+		{"every transfer violation", &pb.TransferFundsRequest{FromId: "x", Amount: &pb.Money{Currency: "USD", Amount: -5}}, []string{"from_id", "to_id", "amount.amount"}},
+		{"valid list", &pb.ListTransactionsRequest{PageSize: 1000}, nil},
+		{"page size above maximum", &pb.ListTransactionsRequest{PageSize: 1001}, []string{"page_size"}},
+		{"negative page size", &pb.ListTransactionsRequest{PageSize: -1}, []string{"page_size"}},
+		{"malformed watched ID", &pb.WatchTransactionsRequest{TransactionIds: []string{validID, "nope"}}, []string{"transaction_ids[1]"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := Validate(test.req)
+			if test.fields == nil {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			st := status.Convert(err)
+			if st.Code() != codes.InvalidArgument {
+				t.Fatalf("Validate() = %v, want InvalidArgument", err)
+			}
+			var fields []string
+			for _, detail := range st.Details() {
+				if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+					for _, violation := range badRequest.FieldViolations {
+						fields = append(fields, violation.Field)
+					}
+				}
+			}
+			if strings.Join(fields, ",") != strings.Join(test.fields, ",") {
+				t.Errorf("violations on %q, want %q", fields, test.fields)
+			}
+		})
+	}
+}
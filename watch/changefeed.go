@@ -0,0 +1,166 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+)
+
+// ChangefeedSource streams changes from a CockroachDB core (sinkless)
+// changefeed on the accounts table. It holds one pooled connection per
+// watcher for as long as the watch runs, and requires the
+// kv.rangefeed.enabled cluster setting.
+type ChangefeedSource struct {
+	db               *pgxpool.Pool
+	resolvedInterval time.Duration
+}
+
+var _ Source = (*ChangefeedSource)(nil)
+
+// NewChangefeedSource returns a source that emits a Checkpoint at least
+// every resolvedInterval.
+func NewChangefeedSource(db *pgxpool.Pool, resolvedInterval time.Duration) *ChangefeedSource {
+	return &ChangefeedSource{db: db, resolvedInterval: resolvedInterval}
+}
+
+// changefeedRow is the wrapped envelope of a changefeed row, as produced
+// with the updated and diff options. Resolved timestamps arrive on rows
+// with no table and only the resolved field set.
+type changefeedRow struct {
+	After    *accountRow `json:"after"`
+	Before   *accountRow `json:"before"`
+	Updated  Cursor      `json:"updated"`
+	Resolved Cursor      `json:"resolved"`
+}
+
+type accountRow struct {
+	ID           uuid.UUID         `json:"id"`
+	Currency     string            `json:"currency"`
+	Balance      int64             `json:"balance"`
+	Version      int64             `json:"version"`
+	TenantID     string            `json:"tenant_id"`
+	OwnerID      string            `json:"owner_id"`
+	Status       repository.Status `json:"status"`
+	StatusReason string            `json:"status_reason"`
+	CreatedAt    changefeedTime    `json:"created_at"`
+	UpdatedAt    changefeedTime    `json:"updated_at"`
+	DeletedAt    *changefeedTime   `json:"deleted_at"`
+}
+
+func (r *accountRow) deleted() bool {
+	return r.DeletedAt != nil
+}
+
+func (r *accountRow) account() repository.Account {
+	account := repository.Account{
+		ID:           r.ID,
+		Currency:     r.Currency,
+		Balance:      r.Balance,
+		Version:      r.Version,
+		TenantID:     r.TenantID,
+		OwnerID:      r.OwnerID,
+		Status:       r.Status,
+		StatusReason: r.StatusReason,
+		CreatedAt:    time.Time(r.CreatedAt),
+		UpdatedAt:    time.Time(r.UpdatedAt),
+	}
+	if r.DeletedAt != nil {
+		account.DeletedAt = time.Time(*r.DeletedAt)
+	}
+	return account
+}
+
+// changefeedTime parses TIMESTAMPTZ values as the changefeed renders them.
+type changefeedTime time.Time
+
+func (t *changefeedTime) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			*t = changefeedTime(parsed.UTC())
+			return nil
+		}
+	}
+	return fmt.Errorf("unrecognized timestamp %q", s)
+}
+
+func (s *ChangefeedSource) Watch(ctx context.Context, cursor Cursor, events chan<- Event) error {
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	// Changefeed options cannot be bound as parameters. The cursor has
+	// been validated by ParseCursor and the interval is a Go duration, so
+	// neither can inject SQL.
+	query := fmt.Sprintf("EXPERIMENTAL CHANGEFEED FOR accounts WITH updated, diff, resolved = '%gs'", s.resolvedInterval.Seconds())
+	if cursor != "" {
+		query += fmt.Sprintf(", cursor = '%s'", cursor)
+	} else {
+		query += ", initial_scan = 'no'"
+	}
+
+	rows, err := conn.Query(ctx, query, pgx.QueryExecModeSimpleProtocol)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer rows.Close()
+
+	started := false
+	for rows.Next() {
+		started = true
+		var table *string
+		var key, value []byte
+		if err := rows.Scan(&table, &key, &value); err != nil {
+			return err
+		}
+
+		var row changefeedRow
+		if err := json.Unmarshal(value, &row); err != nil {
+			return fmt.Errorf("decoding changefeed row: %w", err)
+		}
+
+		// Deletion sets deleted_at, so subscribers see it as the row
+		// turning deleted; purging the row later is not reported again.
+		var event Event
+		switch {
+		case row.Resolved != "":
+			event = Event{Type: Checkpoint, Cursor: row.Resolved}
+		case row.After == nil && row.Before != nil && !row.Before.deleted():
+			event = Event{Type: Deleted, Account: row.Before.account(), Cursor: row.Updated}
+		case row.After == nil, row.After.deleted() && row.Before != nil && row.Before.deleted():
+			continue
+		case row.After.deleted():
+			event = Event{Type: Deleted, Account: row.After.account(), Cursor: row.Updated}
+		case row.Before == nil, row.Before.deleted():
+			event = Event{Type: Created, Account: row.After.account(), Cursor: row.Updated}
+		default:
+			event = Event{Type: Updated, Account: row.After.account(), Cursor: row.Updated}
+		}
+		if err := send(ctx, events, event); err != nil {
+			return err
+		}
+	}
+
+	err = rows.Err()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil && !started {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	if err == nil {
+		err = fmt.Errorf("changefeed ended unexpectedly")
+	}
+	return err
+}
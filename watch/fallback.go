@@ -0,0 +1,35 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+)
+
+// FallbackSource watches through primary and switches to fallback, for this
+// and every later watch, the first time primary reports ErrUnavailable.
+type FallbackSource struct {
+	primary     Source
+	fallback    Source
+	unavailable atomic.Bool
+}
+
+var _ Source = (*FallbackSource)(nil)
+
+func NewFallbackSource(primary, fallback Source) *FallbackSource {
+	return &FallbackSource{primary: primary, fallback: fallback}
+}
+
+func (s *FallbackSource) Watch(ctx context.Context, cursor Cursor, events chan<- Event) error {
+	if !s.unavailable.Load() {
+		err := s.primary.Watch(ctx, cursor, events)
+		if !errors.Is(err, ErrUnavailable) {
+			return err
+		}
+		if s.unavailable.CompareAndSwap(false, true) {
+			slog.Warn("change source unavailable, falling back to polling", "error", err)
+		}
+	}
+	return s.fallback.Watch(ctx, cursor, events)
+}
@@ -0,0 +1,95 @@
+package watch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/money"
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+)
+
+// nextChange returns the next non-checkpoint event.
+func nextChange(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Type != Checkpoint {
+				return event
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for an event")
+		}
+	}
+}
+
+func TestPollSource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repo := repository.NewMemoryRepository()
+	source := NewPollSource(repo, 10*time.Millisecond)
+	events := make(chan Event, 16)
+	go source.Watch(ctx, "", events)
+
+	// The first checkpoint marks the starting snapshot.
+	if first := <-events; first.Type != Checkpoint {
+		t.Fatalf("first event = %v, want checkpoint", first.Type)
+	}
+
+	account, err := repo.Create(ctx, money.New("USD", 100), repository.StatusActive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event := nextChange(t, events); event.Type != Created || event.Account.ID != account.ID {
+		t.Fatalf("got %v for %s, want created for %s", event.Type, event.Account.ID, account.ID)
+	}
+
+	if _, err := repo.UpdateBalance(ctx, account.ID, money.New("USD", 50), nil); err != nil {
+		t.Fatal(err)
+	}
+	event := nextChange(t, events)
+	if event.Type != Updated || event.Account.Balance != 50 {
+		t.Fatalf("got %v with balance %d, want updated with balance 50", event.Type, event.Account.Balance)
+	}
+	resumeFrom := event.Cursor
+
+	if err := repo.Delete(ctx, account.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if event := nextChange(t, events); event.Type != Deleted || event.Account.ID != account.ID {
+		t.Fatalf("got %v for %s, want deleted for %s", event.Type, event.Account.ID, account.ID)
+	}
+	cancel()
+
+	// Resuming replays accounts changed after the cursor.
+	other, err := repo.Create(context.Background(), money.New("EUR", 5), repository.StatusActive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	resumed := make(chan Event, 16)
+	go source.Watch(ctx, resumeFrom, resumed)
+	if event := nextChange(t, resumed); event.Type != Created || event.Account.ID != other.ID {
+		t.Fatalf("resumed stream got %v for %s, want created for %s", event.Type, event.Account.ID, other.ID)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	cursor := CursorAt(at)
+	if !cursor.Time().Equal(at) {
+		t.Errorf("CursorAt(%v).Time() = %v", at, cursor.Time())
+	}
+	if _, err := ParseCursor(string(cursor)); err != nil {
+		t.Errorf("ParseCursor(%q): %v", cursor, err)
+	}
+	for _, bad := range []string{"", "abc", "1.2.3", "1'; DROP TABLE accounts; --"} {
+		if _, err := ParseCursor(bad); err == nil {
+			t.Errorf("ParseCursor(%q) succeeded", bad)
+		}
+	}
+}
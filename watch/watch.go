@@ -0,0 +1,106 @@
+// Package watch streams account changes to WatchTransactions subscribers.
+//
+// A Source produces events; ChangefeedSource reads a CockroachDB changefeed
+// and PollSource diffs periodic snapshots of any AccountRepository, so
+// watching also works against MemoryRepository and databases without
+// rangefeeds. Both use CockroachDB HLC timestamps as cursors, so a client can
+// resume from a cursor regardless of which source issued it.
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yaninyzwitty/golang-proj-with-db/repository"
+)
+
+// ErrUnavailable is returned by a Source that cannot start in the current
+// environment, e.g. a changefeed when rangefeeds are disabled.
+var ErrUnavailable = errors.New("change source unavailable")
+
+// EventType says what happened to an account.
+type EventType int
+
+const (
+	// Created is also sent when a deleted account is restored.
+	Created EventType = iota + 1
+	Updated
+	Deleted
+	// Checkpoint carries no change. It promises that every change up to
+	// its cursor has already been delivered.
+	Checkpoint
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Created:
+		return "created"
+	case Updated:
+		return "updated"
+	case Deleted:
+		return "deleted"
+	case Checkpoint:
+		return "checkpoint"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event is a single change, or a checkpoint.
+type Event struct {
+	Type EventType
+	// Account is the state after the change, or for Deleted the last
+	// state seen before it. It is empty for Checkpoint.
+	Account repository.Account
+	// Cursor resumes the stream after this event. Resuming may replay
+	// events that share the cursor; delivery is at least once.
+	Cursor Cursor
+}
+
+// Source produces account change events.
+type Source interface {
+	// Watch sends events for changes committed after cursor, or after the
+	// call if cursor is empty, until ctx is cancelled or the source fails.
+	// It never closes events.
+	Watch(ctx context.Context, cursor Cursor, events chan<- Event) error
+}
+
+// Cursor is a CockroachDB HLC timestamp: wall-clock nanoseconds since the
+// Unix epoch and a logical counter, written as "<wall>.<logical>".
+type Cursor string
+
+var cursorPattern = regexp.MustCompile(`^[0-9]{1,20}(\.[0-9]{1,10})?$`)
+
+// ParseCursor validates a client-supplied cursor.
+func ParseCursor(s string) (Cursor, error) {
+	if !cursorPattern.MatchString(s) {
+		return "", fmt.Errorf("malformed cursor %q", s)
+	}
+	return Cursor(s), nil
+}
+
+// CursorAt returns the cursor for wall-clock time t.
+func CursorAt(t time.Time) Cursor {
+	return Cursor(fmt.Sprintf("%d.0000000000", t.UnixNano()))
+}
+
+// Time returns the wall-clock part of the cursor.
+func (c Cursor) Time() time.Time {
+	wall, _, _ := strings.Cut(string(c), ".")
+	nanos, _ := strconv.ParseInt(wall, 10, 64)
+	return time.Unix(0, nanos).UTC()
+}
+
+// send delivers an event unless ctx is cancelled first.
+func send(ctx context.Context, events chan<- Event, event Event) error {
+	select {
+	case events <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}